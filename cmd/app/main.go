@@ -19,6 +19,9 @@ import (
 	"github.com/joshbarros/golang-chat-api/internal/workerpool"
 	db_pkg "github.com/joshbarros/golang-chat-api/pkg/db"
 	"github.com/joshbarros/golang-chat-api/pkg/middleware"
+	"github.com/joshbarros/golang-chat-api/pkg/security"
+	"github.com/joshbarros/golang-chat-api/pkg/storage"
+	"github.com/joshbarros/golang-chat-api/pkg/unfurl"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"go.opentelemetry.io/otel"
@@ -80,6 +83,46 @@ func connectWithRetry(connStr string, maxRetries int) (*sql.DB, error) {
 	return nil, fmt.Errorf("could not connect to database after %d retries: %v", maxRetries, err)
 }
 
+// newBlobStore returns the store configured for attachments
+func newBlobStore(cfg *config.Config) (storage.BlobStore, error) {
+	switch cfg.StorageDriver {
+	case "local":
+		return storage.NewLocalStore(cfg.StorageLocalPath)
+	case "s3":
+		store, err := storage.NewS3Store(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := store.EnsureBucket(context.Background()); err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}
+
+// urlSigningKey returns the key signing attachment download links
+func urlSigningKey(cfg *config.Config) []byte {
+	if cfg.AttachmentURLSecret != "" {
+		return []byte(cfg.AttachmentURLSecret)
+	}
+
+	// Links signed with a random key only work on this instance until it restarts
+	log.Println("ATTACHMENT_URL_SECRET is not set, using a random key for download links")
+	key, err := security.RandomToken(32)
+	if err != nil {
+		log.Fatalf("Failed to generate URL signing key: %v", err)
+	}
+	return []byte(key)
+}
+
 // @title Golang Chat API
 // @version 1.0
 // @description This is a Golang Chat API for real-time chat.
@@ -130,29 +173,115 @@ func main() {
 	redisClient := db_pkg.InitRedisClient(cfg.RedisHost, cfg.RedisPort)
 	userRepo := repository.NewUserRepository(db)
   roomRepo := repository.NewRoomRepository(db)
+  memberRepo := repository.NewMemberRepository(db)
+  inviteRepo := repository.NewInviteRepository(db)
+  moderationRepo := repository.NewModerationRepository(db)
   messageRepo := repository.NewMessageRepository(db)
+  reactionRepo := repository.NewReactionRepository(db)
+  readStateRepo := repository.NewReadStateRepository(db)
+  mentionRepo := repository.NewMentionRepository(db)
+  searchRepo := repository.NewSearchRepository(db)
+  attachmentRepo := repository.NewAttachmentRepository(db)
+  linkPreviewRepo := repository.NewLinkPreviewRepository(db)
+
+  blobStore, err := newBlobStore(cfg)
+  if err != nil {
+    log.Fatalf("Failed to set up attachment storage: %v", err)
+  }
 
   // Initialize Worker Pool with, e.g., 10 workers
   workerPool := workerpool.NewWorkerPool(10, messageRepo)
 
+  // Images are decoded and thumbnailed off the request path
+  thumbnailPool := workerpool.NewTaskPool(cfg.ThumbnailWorkers, 100)
+
+  // Links in messages are fetched in the background as well
+  linkPreviews := usecase.LinkPreviewConfig{CacheTTL: cfg.UnfurlCacheTTL}
+  if cfg.UnfurlEnabled {
+    linkPreviews.Fetcher = unfurl.NewFetcher(unfurl.Config{
+      Timeout:      cfg.UnfurlTimeout,
+      MaxBodySize:  cfg.UnfurlMaxBodySize,
+      MaxRedirects: cfg.UnfurlMaxRedirects,
+      AllowedHosts: cfg.UnfurlAllowedHosts,
+      BlockedHosts: cfg.UnfurlBlockedHosts,
+      UserAgent:    "Mozilla/5.0 (compatible; golang-chat-api link preview)",
+    })
+    linkPreviews.Pool = workerpool.NewTaskPool(cfg.UnfurlWorkers, 100)
+  }
+
 	// Set up use cases
 	userUsecase := usecase.NewUserUsecase(userRepo)
-	chatUsecase := usecase.NewChatUsecase(messageRepo, roomRepo, workerPool)
+	chatUsecase := usecase.NewChatUsecase(messageRepo, roomRepo, memberRepo, inviteRepo, moderationRepo, reactionRepo, readStateRepo, mentionRepo, searchRepo, attachmentRepo, usecase.AttachmentConfig{
+		Store:         blobStore,
+		Signer:        security.NewURLSigner(urlSigningKey(cfg)),
+		MaxSize:       cfg.AttachmentMaxSize,
+		AllowedTypes:  cfg.AttachmentAllowedTypes,
+		URLTTL:        cfg.AttachmentURLTTL,
+		Thumbnails:    thumbnailPool,
+		ThumbnailSize: cfg.ThumbnailSize,
+	}, linkPreviewRepo, linkPreviews, workerPool, redisClient)
+
+	// Tell rooms about users whose connections lapsed on a crashed instance
+	go chatUsecase.SweepLapsedPresence()
 
 	// Set up handlers
 	userHandler := http.NewUserHandler(userUsecase)
-	wsHandler := http.NewWSHandler(chatUsecase, redisClient)
+	wsHandler := http.NewWSHandler(chatUsecase, redisClient, usecase.ClientConfig{
+		SendBufferSize: cfg.WSSendBufferSize,
+		WriteTimeout:   cfg.WSWriteTimeout,
+		PingInterval:   cfg.WSPingInterval,
+		PongWait:       cfg.WSPongWait,
+		IdleTimeout:    cfg.WSIdleTimeout,
+		AckTimeout:     cfg.WSAckTimeout,
+		MaxRetries:     cfg.WSMaxRetries,
+	}, usecase.NewFrameLimiter(cfg.WSRateLimit, cfg.WSRateBurst))
 
 	// Public routes
 	router.POST("/register", userHandler.Register)
 	router.POST("/login", userHandler.Login)
 
+	// Signed download links carry their own authorization
+	router.GET("/attachments/:id/download", wsHandler.DownloadAttachment)
+	router.GET("/attachments/:id/thumbnail", wsHandler.DownloadThumbnail)
+
 	// Protected routes
 	protected := router.Group("/")
 	protected.Use(middleware.JWTAuthMiddleware())
   protected.POST("/rooms", wsHandler.CreateRoom)
   protected.GET("/rooms", wsHandler.GetRooms)
+  protected.POST("/rooms/:roomID/join", wsHandler.JoinRoom)
+  protected.POST("/rooms/:roomID/leave", wsHandler.LeaveRoom)
+  protected.GET("/rooms/:roomID/members", wsHandler.GetRoomMembers)
+  protected.PUT("/rooms/:roomID/members/:userID/role", wsHandler.SetMemberRole)
+  protected.PUT("/rooms/:roomID/slow-mode", wsHandler.SetSlowMode)
+  protected.POST("/rooms/:roomID/members/:userID/kick", wsHandler.KickUser)
+  protected.GET("/rooms/:roomID/bans", wsHandler.GetBans)
+  protected.PUT("/rooms/:roomID/bans/:userID", wsHandler.BanUser)
+  protected.DELETE("/rooms/:roomID/bans/:userID", wsHandler.UnbanUser)
+  protected.PUT("/rooms/:roomID/mutes/:userID", wsHandler.MuteUser)
+  protected.DELETE("/rooms/:roomID/mutes/:userID", wsHandler.UnmuteUser)
+  protected.POST("/rooms/:roomID/invites", wsHandler.CreateInvite)
+  protected.GET("/rooms/:roomID/invites", wsHandler.GetInvites)
+  protected.DELETE("/rooms/:roomID/invites/:id", wsHandler.RevokeInvite)
+  protected.GET("/rooms/:roomID/invites/:id/redemptions", wsHandler.GetInviteRedemptions)
+  protected.POST("/invites/:token/accept", wsHandler.AcceptInvite)
+  protected.POST("/rooms/:roomID/read", wsHandler.MarkRead)
+  protected.GET("/rooms/:roomID/read", wsHandler.GetReadStates)
+  protected.GET("/rooms/:roomID/presence", wsHandler.GetRoomPresence)
+  protected.GET("/search/messages", wsHandler.SearchMessages)
+  protected.POST("/rooms/:roomID/attachments", wsHandler.UploadAttachment)
+  protected.GET("/attachments/:id", wsHandler.GetAttachment)
+  protected.GET("/users/:id/presence", wsHandler.GetUserPresence)
+  protected.PUT("/users/me/presence", wsHandler.SetPresenceStatus)
+  protected.POST("/dms/:userID", wsHandler.OpenDirectRoom)
+  protected.GET("/dms", wsHandler.GetDirectRooms)
   protected.GET("/rooms/:roomID/messages", wsHandler.GetRoomMessages)
+  protected.PATCH("/rooms/:roomID/messages/:id", wsHandler.EditMessage)
+  protected.DELETE("/rooms/:roomID/messages/:id", wsHandler.DeleteMessage)
+  protected.GET("/rooms/:roomID/messages/:id/revisions", wsHandler.GetMessageRevisions)
+  protected.GET("/rooms/:roomID/messages/:id/thread", wsHandler.GetThread)
+  protected.POST("/rooms/:roomID/messages/:id/reactions", wsHandler.AddReaction)
+  protected.DELETE("/rooms/:roomID/messages/:id/reactions/:emoji", wsHandler.RemoveReaction)
 	protected.GET("/ws/:roomID", wsHandler.WebSocketHandler)
 
 	// Prometheus metrics
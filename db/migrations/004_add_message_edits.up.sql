@@ -0,0 +1,13 @@
+ALTER TABLE messages
+    ADD COLUMN edited_at TIMESTAMP,
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE TABLE message_revisions (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_message_revisions_message_id ON message_revisions(message_id);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_messages_parent_id;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS parent_id,
+    DROP COLUMN IF EXISTS reply_count,
+    DROP COLUMN IF EXISTS last_reply_at;
@@ -0,0 +1,6 @@
+ALTER TABLE messages
+    ADD COLUMN parent_id INTEGER REFERENCES messages(id) ON DELETE CASCADE,
+    ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN last_reply_at TIMESTAMP;
+
+CREATE INDEX idx_messages_parent_id ON messages(parent_id, timestamp);
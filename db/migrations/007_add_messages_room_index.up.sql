@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_messages_room_timestamp
+    ON messages(room_id, timestamp DESC, id DESC)
+    WHERE parent_id IS NULL;
@@ -0,0 +1,17 @@
+ALTER TABLE rooms ADD COLUMN last_seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE messages ADD COLUMN seq BIGINT;
+
+-- Number existing messages in the order they were posted
+UPDATE messages m
+SET seq = numbered.seq
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY room_id ORDER BY timestamp, id) AS seq
+    FROM messages
+) numbered
+WHERE m.id = numbered.id;
+
+UPDATE rooms r
+SET last_seq = COALESCE((SELECT MAX(seq) FROM messages WHERE room_id = r.id), 0);
+
+ALTER TABLE messages ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX idx_messages_room_seq ON messages(room_id, seq);
@@ -0,0 +1,5 @@
+ALTER TABLE messages ADD COLUMN client_msg_id VARCHAR(64);
+
+CREATE UNIQUE INDEX idx_messages_client_msg_id
+    ON messages(user_id, room_id, client_msg_id)
+    WHERE client_msg_id IS NOT NULL;
@@ -0,0 +1,11 @@
+ALTER TABLE rooms ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'private'));
+
+CREATE TABLE room_members (
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (room_id, user_id)
+);
+
+CREATE INDEX idx_room_members_user_id ON room_members(user_id);
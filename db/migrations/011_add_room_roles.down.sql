@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_room_members_owner;
+ALTER TABLE room_members DROP COLUMN IF EXISTS role;
+ALTER TABLE rooms DROP COLUMN IF EXISTS created_by;
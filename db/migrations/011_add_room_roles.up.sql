@@ -0,0 +1,7 @@
+ALTER TABLE rooms ADD COLUMN created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE room_members ADD COLUMN role VARCHAR(16) NOT NULL DEFAULT 'member'
+    CHECK (role IN ('owner', 'admin', 'moderator', 'member'));
+
+-- A room has at most one owner
+CREATE UNIQUE INDEX idx_room_members_owner ON room_members(room_id) WHERE role = 'owner';
@@ -0,0 +1,13 @@
+ALTER TABLE rooms ADD COLUMN direct BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Each pair of users shares at most one direct room. Pairs are stored with
+-- the lower user ID first.
+CREATE TABLE direct_rooms (
+    room_id INTEGER PRIMARY KEY REFERENCES rooms(id) ON DELETE CASCADE,
+    user_low INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_high INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_low, user_high),
+    CHECK (user_low < user_high)
+);
+
+CREATE INDEX idx_direct_rooms_user_high ON direct_rooms(user_high);
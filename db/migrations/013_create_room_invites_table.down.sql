@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS room_invite_redemptions;
+DROP TABLE IF EXISTS room_invites;
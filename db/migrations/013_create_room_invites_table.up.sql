@@ -0,0 +1,23 @@
+CREATE TABLE room_invites (
+    id SERIAL PRIMARY KEY,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    room_id INTEGER NOT NULL REFERENCES rooms(id) ON DELETE CASCADE,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    max_uses INTEGER CHECK (max_uses > 0),
+    uses INTEGER NOT NULL DEFAULT 0,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_room_invites_room_id ON room_invites(room_id);
+
+-- Audit trail of the users who joined through each invite
+CREATE TABLE room_invite_redemptions (
+    id SERIAL PRIMARY KEY,
+    invite_id INTEGER NOT NULL REFERENCES room_invites(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redeemed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_room_invite_redemptions_invite_id ON room_invite_redemptions(invite_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS room_mutes;
+DROP TABLE IF EXISTS room_bans;
@@ -0,0 +1 @@
+ALTER TABLE rooms DROP COLUMN IF EXISTS slow_mode;
@@ -0,0 +1 @@
+ALTER TABLE rooms ADD COLUMN slow_mode INTEGER NOT NULL DEFAULT 0 CHECK (slow_mode >= 0);
@@ -0,0 +1,12 @@
+CREATE TABLE message_mentions (
+    id SERIAL PRIMARY KEY,
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    kind VARCHAR(8) NOT NULL CHECK (kind IN ('user', 'room', 'here')),
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE, -- Set for user mentions only
+    start_offset INTEGER NOT NULL,
+    length INTEGER NOT NULL,
+    CHECK ((kind = 'user') = (user_id IS NOT NULL))
+);
+
+CREATE INDEX idx_message_mentions_message_id ON message_mentions(message_id);
+CREATE INDEX idx_message_mentions_user_id ON message_mentions(user_id);
@@ -0,0 +1,7 @@
+ALTER TABLE attachments
+    DROP COLUMN IF EXISTS thumbnail_height,
+    DROP COLUMN IF EXISTS thumbnail_width,
+    DROP COLUMN IF EXISTS thumbnail_content_type,
+    DROP COLUMN IF EXISTS thumbnail_key,
+    DROP COLUMN IF EXISTS height,
+    DROP COLUMN IF EXISTS width;
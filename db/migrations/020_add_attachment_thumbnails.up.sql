@@ -0,0 +1,7 @@
+ALTER TABLE attachments
+    ADD COLUMN width INTEGER, -- Set once an image has been processed
+    ADD COLUMN height INTEGER,
+    ADD COLUMN thumbnail_key VARCHAR(255) UNIQUE,
+    ADD COLUMN thumbnail_content_type VARCHAR(100),
+    ADD COLUMN thumbnail_width INTEGER,
+    ADD COLUMN thumbnail_height INTEGER;
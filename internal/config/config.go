@@ -2,6 +2,8 @@ package config
 
 import (
 	"log"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,26 +17,162 @@ type Config struct {
 	DBName           string
 	RedisHost        string
 	RedisPort        string
+	WSSendBufferSize int
+	WSWriteTimeout   time.Duration
+	WSPingInterval   time.Duration
+	WSPongWait       time.Duration
+	WSIdleTimeout    time.Duration
+	WSAckTimeout     time.Duration
+	WSMaxRetries     int
+	WSRateLimit      float64
+	WSRateBurst      int
+
+	StorageDriver    string // "local" or "s3"
+	StorageLocalPath string
+	S3Endpoint       string
+	S3Region         string
+	S3Bucket         string
+	S3AccessKey      string
+	S3SecretKey      string
+
+	AttachmentMaxSize      int64
+	AttachmentAllowedTypes []string
+	AttachmentURLSecret    string
+	AttachmentURLTTL       time.Duration
+
+	ThumbnailWorkers int
+	ThumbnailSize    int
+
+	UnfurlEnabled      bool
+	UnfurlWorkers      int
+	UnfurlTimeout      time.Duration
+	UnfurlMaxBodySize  int64
+	UnfurlMaxRedirects int
+	UnfurlAllowedHosts []string
+	UnfurlBlockedHosts []string
+	UnfurlCacheTTL     time.Duration
+}
+
+// Defaults of the WebSocket settings that must be positive
+const (
+	defaultWSSendBufferSize = 256
+	defaultWSWriteTimeout   = 10 * time.Second
+	defaultWSPingInterval   = 30 * time.Second
+	defaultWSPongWait       = 60 * time.Second
+	defaultWSAckTimeout     = 10 * time.Second
+
+	// Unacknowledged frames are checked every half ack timeout, which must
+	// not turn into a busy loop
+	minWSAckTimeout = 100 * time.Millisecond
+)
+
+// positiveInt reads an integer setting that must be positive, falling back to
+// def when it is not
+func positiveInt(key string, def int) int {
+	if v := viper.GetInt(key); v > 0 {
+		return v
+	}
+	log.Printf("%s must be positive, using %d", key, def)
+	return def
+}
+
+// positiveDuration reads a duration setting that must be positive, falling
+// back to def when it is not
+func positiveDuration(key string, def time.Duration) time.Duration {
+	if d := viper.GetDuration(key); d > 0 {
+		return d
+	}
+	log.Printf("%s must be positive, using %s", key, def)
+	return def
+}
+
+// durationAtLeast reads a duration setting that must be at least min,
+// falling back to def when it is not
+func durationAtLeast(key string, min, def time.Duration) time.Duration {
+	if d := viper.GetDuration(key); d >= min {
+		return d
+	}
+	log.Printf("%s must be at least %s, using %s", key, min, def)
+	return def
 }
 
 func LoadConfig() *Config {
 	viper.SetConfigFile(".env") // Look for .env in the root
 	viper.AutomaticEnv()        // Read environment variables that are set in the system
 
+	// Defaults for optional settings
+	viper.SetDefault("WS_SEND_BUFFER_SIZE", defaultWSSendBufferSize)
+	viper.SetDefault("WS_WRITE_TIMEOUT", defaultWSWriteTimeout)
+	viper.SetDefault("WS_PING_INTERVAL", defaultWSPingInterval)
+	viper.SetDefault("WS_PONG_WAIT", defaultWSPongWait)
+	viper.SetDefault("WS_IDLE_TIMEOUT", 0) // Off, quiet rooms have readers that send nothing
+	viper.SetDefault("WS_ACK_TIMEOUT", defaultWSAckTimeout)
+	viper.SetDefault("WS_MAX_DELIVERY_RETRIES", 5)
+	viper.SetDefault("WS_RATE_LIMIT", 5)
+	viper.SetDefault("WS_RATE_BURST", 10)
+	viper.SetDefault("STORAGE_DRIVER", "local")
+	viper.SetDefault("STORAGE_LOCAL_PATH", "./data/attachments")
+	viper.SetDefault("S3_REGION", "us-east-1")
+	viper.SetDefault("ATTACHMENT_MAX_SIZE", 10<<20)
+	viper.SetDefault("ATTACHMENT_ALLOWED_TYPES", "image/png,image/jpeg,image/gif,image/webp,application/pdf,text/plain")
+	viper.SetDefault("ATTACHMENT_URL_TTL", "15m")
+	viper.SetDefault("THUMBNAIL_WORKERS", 2)
+	viper.SetDefault("THUMBNAIL_SIZE", 320)
+	viper.SetDefault("UNFURL_ENABLED", true)
+	viper.SetDefault("UNFURL_WORKERS", 4)
+	viper.SetDefault("UNFURL_TIMEOUT", "5s")
+	viper.SetDefault("UNFURL_MAX_BODY_SIZE", 512<<10)
+	viper.SetDefault("UNFURL_MAX_REDIRECTS", 3)
+	viper.SetDefault("UNFURL_CACHE_TTL", "24h")
+
 	err := viper.ReadInConfig()
 	if err != nil {
 		log.Println("No .env file found, using system environment variables...")
 	}
 
 	config := &Config{
-		Port:      viper.GetString("PORT"),
-		DBHost:    viper.GetString("DB_HOST"),
-		DBPort:    viper.GetString("DB_PORT"),
-		DBUser:    viper.GetString("DB_USER"),
-		DBPass:    viper.GetString("DB_PASS"),
-		DBName:    viper.GetString("DB_NAME"),
-		RedisHost: viper.GetString("REDIS_HOST"),
-		RedisPort: viper.GetString("REDIS_PORT"),
+		Port:             viper.GetString("PORT"),
+		DBHost:           viper.GetString("DB_HOST"),
+		DBPort:           viper.GetString("DB_PORT"),
+		DBUser:           viper.GetString("DB_USER"),
+		DBPass:           viper.GetString("DB_PASS"),
+		DBName:           viper.GetString("DB_NAME"),
+		RedisHost:        viper.GetString("REDIS_HOST"),
+		RedisPort:        viper.GetString("REDIS_PORT"),
+		WSSendBufferSize: positiveInt("WS_SEND_BUFFER_SIZE", defaultWSSendBufferSize),
+		WSWriteTimeout:   positiveDuration("WS_WRITE_TIMEOUT", defaultWSWriteTimeout),
+		WSPingInterval:   positiveDuration("WS_PING_INTERVAL", defaultWSPingInterval),
+		WSPongWait:       positiveDuration("WS_PONG_WAIT", defaultWSPongWait),
+		WSIdleTimeout:    viper.GetDuration("WS_IDLE_TIMEOUT"),
+		WSAckTimeout:     durationAtLeast("WS_ACK_TIMEOUT", minWSAckTimeout, defaultWSAckTimeout),
+		WSMaxRetries:     viper.GetInt("WS_MAX_DELIVERY_RETRIES"),
+		WSRateLimit:      viper.GetFloat64("WS_RATE_LIMIT"),
+		WSRateBurst:      viper.GetInt("WS_RATE_BURST"),
+
+		StorageDriver:    viper.GetString("STORAGE_DRIVER"),
+		StorageLocalPath: viper.GetString("STORAGE_LOCAL_PATH"),
+		S3Endpoint:       viper.GetString("S3_ENDPOINT"),
+		S3Region:         viper.GetString("S3_REGION"),
+		S3Bucket:         viper.GetString("S3_BUCKET"),
+		S3AccessKey:      viper.GetString("S3_ACCESS_KEY"),
+		S3SecretKey:      viper.GetString("S3_SECRET_KEY"),
+
+		AttachmentMaxSize:      viper.GetInt64("ATTACHMENT_MAX_SIZE"),
+		AttachmentAllowedTypes: strings.Split(viper.GetString("ATTACHMENT_ALLOWED_TYPES"), ","),
+		AttachmentURLSecret:    viper.GetString("ATTACHMENT_URL_SECRET"),
+		AttachmentURLTTL:       viper.GetDuration("ATTACHMENT_URL_TTL"),
+
+		ThumbnailWorkers: viper.GetInt("THUMBNAIL_WORKERS"),
+		ThumbnailSize:    viper.GetInt("THUMBNAIL_SIZE"),
+
+		UnfurlEnabled:      viper.GetBool("UNFURL_ENABLED"),
+		UnfurlWorkers:      viper.GetInt("UNFURL_WORKERS"),
+		UnfurlTimeout:      viper.GetDuration("UNFURL_TIMEOUT"),
+		UnfurlMaxBodySize:  viper.GetInt64("UNFURL_MAX_BODY_SIZE"),
+		UnfurlMaxRedirects: viper.GetInt("UNFURL_MAX_REDIRECTS"),
+		UnfurlAllowedHosts: strings.Split(viper.GetString("UNFURL_ALLOWED_HOSTS"), ","),
+		UnfurlBlockedHosts: strings.Split(viper.GetString("UNFURL_BLOCKED_HOSTS"), ","),
+		UnfurlCacheTTL:     viper.GetDuration("UNFURL_CACHE_TTL"),
 	}
 
 	return config
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead is the room left for the multipart framing around an
+// upload when limiting the size of the request body
+const multipartOverhead = 1 << 20
+
+// UploadAttachment godoc
+// @Summary Upload an attachment
+// @Description Upload a file to a room as multipart form data. The file type is detected from its content and must be on the allow-list. Send the returned ID in attachment_ids with a message to attach it.
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param file formData file true "File to upload"
+// @Success 201 {object} domain.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/attachments [post]
+func (h *WSHandler) UploadAttachment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	maxSize := h.chatUsecase.MaxAttachmentSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to read upload"})
+		return
+	}
+	defer file.Close()
+
+	attachment, err := h.chatUsecase.UploadAttachment(c.Param("roomID"), userID, header.Filename, file, header.Size)
+	if err != nil {
+		respondError(c, err, "Unable to store upload")
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// GetAttachment godoc
+// @Summary Get an attachment
+// @Description Get an attachment with a fresh signed download link
+// @Tags attachments
+// @Produce json
+// @Param id path int true "Attachment ID"
+// @Success 200 {object} domain.Attachment
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /attachments/{id} [get]
+func (h *WSHandler) GetAttachment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	attachment, err := h.chatUsecase.GetAttachment(attachmentID, userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch attachment")
+		return
+	}
+
+	c.JSON(http.StatusOK, attachment)
+}
+
+// DownloadAttachment godoc
+// @Summary Download an attachment
+// @Description Download the content of an attachment through a signed link. No token is needed; the link itself grants access until it expires.
+// @Tags attachments
+// @Produce octet-stream
+// @Param id path int true "Attachment ID"
+// @Param expires query int true "Expiry of the link (Unix time)"
+// @Param signature query string true "Signature of the link"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /attachments/{id}/download [get]
+func (h *WSHandler) DownloadAttachment(c *gin.Context) {
+	attachmentID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires"})
+		return
+	}
+
+	attachment, blob, err := h.chatUsecase.OpenAttachment(attachmentID, expires, c.Query("signature"))
+	if err != nil {
+		respondError(c, err, "Unable to download attachment")
+		return
+	}
+	defer blob.Close()
+
+	// Only images are shown inline, anything else is saved by the browser
+	disposition := "attachment"
+	if strings.HasPrefix(attachment.ContentType, "image/") {
+		disposition = "inline"
+	}
+
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", max(0, expires-time.Now().Unix())))
+	c.Status(http.StatusOK)
+
+	if _, err := io.Copy(c.Writer, blob); err != nil {
+		log.Printf("Error sending attachment %d: %v", attachmentID, err)
+	}
+}
+
+// DownloadThumbnail godoc
+// @Summary Download the thumbnail of an image attachment
+// @Description Download the thumbnail of an image attachment through a signed link. Thumbnails are made in the background after the upload; an attachment.update frame tells when one is ready.
+// @Tags attachments
+// @Produce image/jpeg,image/png
+// @Param id path int true "Attachment ID"
+// @Param expires query int true "Expiry of the link (Unix time)"
+// @Param signature query string true "Signature of the link"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /attachments/{id}/thumbnail [get]
+func (h *WSHandler) DownloadThumbnail(c *gin.Context) {
+	attachmentID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expires"})
+		return
+	}
+
+	thumbnail, blob, err := h.chatUsecase.OpenThumbnail(attachmentID, expires, c.Query("signature"))
+	if err != nil {
+		respondError(c, err, "Unable to download thumbnail")
+		return
+	}
+	defer blob.Close()
+
+	c.Header("Content-Type", thumbnail.ContentType)
+	c.Header("Content-Disposition", "inline")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", max(0, expires-time.Now().Unix())))
+	c.Status(http.StatusOK)
+
+	if _, err := io.Copy(c.Writer, blob); err != nil {
+		log.Printf("Error sending thumbnail of attachment %d: %v", attachmentID, err)
+	}
+}
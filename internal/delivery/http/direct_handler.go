@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenDirectRoom godoc
+// @Summary Open a direct conversation
+// @Description Find or create the private room shared by the caller and another user. Messages are exchanged over the room's WebSocket like in any other room.
+// @Tags dms
+// @Produce json
+// @Param userID path int true "ID of the other user"
+// @Success 200 {object} domain.Room
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /dms/{userID} [post]
+func (h *WSHandler) OpenDirectRoom(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	otherID, ok := intParam(c, "userID")
+	if !ok {
+		return
+	}
+
+	room, err := h.chatUsecase.OpenDirectRoom(userID, otherID)
+	if err != nil {
+		respondError(c, err, "Unable to open direct conversation")
+		return
+	}
+
+	c.JSON(http.StatusOK, room)
+}
+
+// GetDirectRooms godoc
+// @Summary List direct conversations
+// @Description List the caller's direct conversations with the other participant and the last message, most recently active first
+// @Tags dms
+// @Produce json
+// @Success 200 {array} domain.DirectConversation
+// @Failure 500 {object} map[string]string
+// @Router /dms [get]
+func (h *WSHandler) GetDirectRooms(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	conversations, err := h.chatUsecase.GetDirectRooms(userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch direct conversations")
+		return
+	}
+
+	c.JSON(http.StatusOK, conversations)
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// frameError maps a usecase error to an error frame code and message
+func frameError(err error) (string, string) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		return domain.ErrCodeBadRequest, "Invalid frame payload"
+	case errors.Is(err, domain.ErrNotFound):
+		return domain.ErrCodeNotFound, "Not found"
+	case errors.Is(err, domain.ErrForbidden):
+		return domain.ErrCodeForbidden, "Forbidden"
+	case errors.Is(err, domain.ErrRateLimited):
+		return domain.ErrCodeRateLimited, "Too many messages, slow down"
+	default:
+		return domain.ErrCodeInternal, "Unable to process frame"
+	}
+}
+
+// respondError writes the HTTP status matching a usecase error. fallback is
+// the message used for unexpected errors.
+func respondError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidInput):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+	case errors.Is(err, domain.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, domain.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+	case errors.Is(err, domain.ErrRateLimited):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+	case errors.Is(err, domain.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File too large"})
+	case errors.Is(err, domain.ErrUnsupportedType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type not allowed"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// currentUserID returns the ID of the user authenticated by JWTAuthMiddleware
+func currentUserID(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.GetString("userID"))
+	if err != nil || userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// intParam parses a numeric path parameter
+func intParam(c *gin.Context, name string) (int, bool) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+		return 0, false
+	}
+	return value, true
+}
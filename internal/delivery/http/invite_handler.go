@@ -0,0 +1,167 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateInviteRequest defines the request body for minting an invite
+type CreateInviteRequest struct {
+	ExpiresIn int `json:"expires_in"` // Lifetime in seconds, 24 hours when omitted
+	MaxUses   int `json:"max_uses"`   // Unlimited when omitted
+}
+
+// CreateInvite godoc
+// @Summary Create an invite to a room
+// @Description Mint an invite token that lets other users join the room. Only owners and admins may create invites.
+// @Tags invites
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param request body CreateInviteRequest false "Invite limits"
+// @Success 201 {object} domain.Invite
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/invites [post]
+func (h *WSHandler) CreateInvite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req CreateInviteRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+
+	ttl := time.Duration(req.ExpiresIn) * time.Second
+	invite, err := h.chatUsecase.CreateInvite(c.Param("roomID"), userID, ttl, req.MaxUses)
+	if err != nil {
+		respondError(c, err, "Unable to create invite")
+		return
+	}
+
+	c.JSON(http.StatusCreated, invite)
+}
+
+// GetInvites godoc
+// @Summary List the invites of a room
+// @Description List every invite of the room, including revoked and expired ones. Only owners and admins may list invites.
+// @Tags invites
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Success 200 {array} domain.Invite
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/invites [get]
+func (h *WSHandler) GetInvites(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	invites, err := h.chatUsecase.GetInvites(c.Param("roomID"), userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch invites")
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// RevokeInvite godoc
+// @Summary Revoke an invite
+// @Description Stop an invite from being accepted. Only owners and admins may revoke invites.
+// @Tags invites
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Invite ID"
+// @Success 200 {object} domain.Invite
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/invites/{id} [delete]
+func (h *WSHandler) RevokeInvite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	inviteID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	invite, err := h.chatUsecase.RevokeInvite(c.Param("roomID"), inviteID, userID)
+	if err != nil {
+		respondError(c, err, "Unable to revoke invite")
+		return
+	}
+
+	c.JSON(http.StatusOK, invite)
+}
+
+// GetInviteRedemptions godoc
+// @Summary List who joined through an invite
+// @Description Audit the users who joined the room through an invite, oldest first. Only owners and admins may see them.
+// @Tags invites
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Invite ID"
+// @Success 200 {array} domain.InviteRedemption
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/invites/{id}/redemptions [get]
+func (h *WSHandler) GetInviteRedemptions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	inviteID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	redemptions, err := h.chatUsecase.GetInviteRedemptions(c.Param("roomID"), inviteID, userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch redemptions")
+		return
+	}
+
+	c.JSON(http.StatusOK, redemptions)
+}
+
+// AcceptInvite godoc
+// @Summary Accept an invite
+// @Description Join the room an invite is for. Unknown, revoked, expired and used up invites are not found.
+// @Tags invites
+// @Produce json
+// @Param token path string true "Invite token"
+// @Success 200 {object} domain.Room
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /invites/{token}/accept [post]
+func (h *WSHandler) AcceptInvite(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	room, err := h.chatUsecase.AcceptInvite(c.Param("token"), userID)
+	if err != nil {
+		respondError(c, err, "Unable to accept invite")
+		return
+	}
+
+	c.JSON(http.StatusOK, room)
+}
@@ -0,0 +1,220 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReactionRequest defines the request body for reacting to a message
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+// EditMessageRequest defines the request body for editing a message
+type EditMessageRequest struct {
+	Message string `json:"message"`
+}
+
+// EditMessage godoc
+// @Summary Edit a message
+// @Description Change the text of a message. Only the author may edit it.
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Message ID"
+// @Param request body EditMessageRequest true "New text"
+// @Success 200 {object} domain.Message
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/messages/{id} [patch]
+func (h *WSHandler) EditMessage(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	messageID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	msg, err := h.chatUsecase.EditMessage(c.Param("roomID"), messageID, userID, req.Message)
+	if err != nil {
+		respondError(c, err, "Unable to edit message")
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// DeleteMessage godoc
+// @Summary Delete a message
+// @Description Soft-delete a message, leaving a tombstone in the room history. The author and the moderators of the room may delete it.
+// @Tags messages
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Message ID"
+// @Success 200 {object} domain.Message
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/messages/{id} [delete]
+func (h *WSHandler) DeleteMessage(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	messageID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	msg, err := h.chatUsecase.DeleteMessage(c.Param("roomID"), messageID, userID)
+	if err != nil {
+		respondError(c, err, "Unable to delete message")
+		return
+	}
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// GetMessageRevisions godoc
+// @Summary Get the edit history of a message
+// @Description List the previous bodies of a message. Only the author may see them.
+// @Tags messages
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Message ID"
+// @Success 200 {array} domain.MessageRevision
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/messages/{id}/revisions [get]
+func (h *WSHandler) GetMessageRevisions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	messageID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	revisions, err := h.chatUsecase.GetMessageRevisions(c.Param("roomID"), messageID, userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch revisions")
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetThread godoc
+// @Summary Get a message thread
+// @Description Fetch a top-level message together with its replies, oldest first
+// @Tags messages
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Message ID"
+// @Success 200 {object} domain.Thread
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/messages/{id}/thread [get]
+func (h *WSHandler) GetThread(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	messageID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	thread, err := h.chatUsecase.GetThread(c.Param("roomID"), messageID, userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch thread")
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+// AddReaction godoc
+// @Summary React to a message
+// @Description Add an emoji reaction to a message. Reacting twice with the same emoji has no effect.
+// @Tags reactions
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Message ID"
+// @Param request body ReactionRequest true "Reaction"
+// @Success 200 {object} domain.ReactionPayload
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/messages/{id}/reactions [post]
+func (h *WSHandler) AddReaction(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	messageID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	reaction, err := h.chatUsecase.AddReaction(c.Param("roomID"), messageID, userID, req.Emoji)
+	if err != nil {
+		respondError(c, err, "Unable to add reaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, reaction)
+}
+
+// RemoveReaction godoc
+// @Summary Remove a reaction from a message
+// @Description Withdraw the caller's emoji reaction from a message
+// @Tags reactions
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param id path int true "Message ID"
+// @Param emoji path string true "Emoji"
+// @Success 200 {object} domain.ReactionPayload
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/messages/{id}/reactions/{emoji} [delete]
+func (h *WSHandler) RemoveReaction(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	messageID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	reaction, err := h.chatUsecase.RemoveReaction(c.Param("roomID"), messageID, userID, c.Param("emoji"))
+	if err != nil {
+		respondError(c, err, "Unable to remove reaction")
+		return
+	}
+
+	c.JSON(http.StatusOK, reaction)
+}
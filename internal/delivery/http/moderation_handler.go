@@ -0,0 +1,289 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/usecase"
+)
+
+// ModerationRequest defines the request body for kicking, banning and muting
+type ModerationRequest struct {
+	Duration int    `json:"duration"` // In seconds; bans without a duration are permanent
+	Reason   string `json:"reason"`
+}
+
+// bindModeration parses the optional moderation request body
+func bindModeration(c *gin.Context) (ModerationRequest, bool) {
+	var req ModerationRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// moderationDuration converts a moderation duration given in seconds. Counts
+// beyond the longest ban are rejected here, before they can overflow.
+func moderationDuration(seconds int) (time.Duration, error) {
+	if seconds < 0 || int64(seconds) > int64(usecase.MaxBanDuration/time.Second) {
+		return 0, fmt.Errorf("invalid duration of %d seconds: %w", seconds, domain.ErrInvalidInput)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// SlowModeRequest defines the request body for setting slow mode
+type SlowModeRequest struct {
+	Seconds int `json:"seconds"` // 0 turns slow mode off
+}
+
+// SetSlowMode godoc
+// @Summary Set slow mode for a room
+// @Description Make members wait a number of seconds, up to 6 hours, between messages. Moderators and above are not slowed down. Only admins and owners may change it.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param request body SlowModeRequest true "Delay between messages"
+// @Success 200 {object} domain.Room
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/slow-mode [put]
+func (h *WSHandler) SetSlowMode(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req SlowModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	room, err := h.chatUsecase.SetSlowMode(c.Param("roomID"), userID, req.Seconds)
+	if err != nil {
+		respondError(c, err, "Unable to set slow mode")
+		return
+	}
+
+	c.JSON(http.StatusOK, room)
+}
+
+// KickUser godoc
+// @Summary Kick a user from a room
+// @Description Remove a user from the room and close their connections to it right away. Only moderators and above may kick, and only members ranked below them.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param userID path int true "User ID"
+// @Param request body ModerationRequest false "Reason"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/members/{userID}/kick [post]
+func (h *WSHandler) KickUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	targetID, ok := intParam(c, "userID")
+	if !ok {
+		return
+	}
+	req, ok := bindModeration(c)
+	if !ok {
+		return
+	}
+
+	if err := h.chatUsecase.KickUser(c.Param("roomID"), userID, targetID, req.Reason); err != nil {
+		respondError(c, err, "Unable to kick user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User kicked"})
+}
+
+// GetBans godoc
+// @Summary List the bans of a room
+// @Description List the bans in effect in the room. Only admins and owners may see them.
+// @Tags moderation
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Success 200 {array} domain.RoomBan
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/bans [get]
+func (h *WSHandler) GetBans(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	bans, err := h.chatUsecase.GetBans(c.Param("roomID"), userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch bans")
+		return
+	}
+
+	c.JSON(http.StatusOK, bans)
+}
+
+// BanUser godoc
+// @Summary Ban a user from a room
+// @Description Remove a user from the room, close their connections and keep them from rejoining until the ban expires, after up to a year, or for good when no duration is given. Only admins and owners may ban.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param userID path int true "User ID"
+// @Param request body ModerationRequest false "Duration and reason"
+// @Success 200 {object} domain.RoomBan
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/bans/{userID} [put]
+func (h *WSHandler) BanUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	targetID, ok := intParam(c, "userID")
+	if !ok {
+		return
+	}
+	req, ok := bindModeration(c)
+	if !ok {
+		return
+	}
+
+	duration, err := moderationDuration(req.Duration)
+	if err != nil {
+		respondError(c, err, "Unable to ban user")
+		return
+	}
+	ban, err := h.chatUsecase.BanUser(c.Param("roomID"), userID, targetID, duration, req.Reason)
+	if err != nil {
+		respondError(c, err, "Unable to ban user")
+		return
+	}
+
+	c.JSON(http.StatusOK, ban)
+}
+
+// UnbanUser godoc
+// @Summary Lift a ban
+// @Description Let a banned user rejoin the room. Only admins and owners may lift bans.
+// @Tags moderation
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param userID path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/bans/{userID} [delete]
+func (h *WSHandler) UnbanUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	targetID, ok := intParam(c, "userID")
+	if !ok {
+		return
+	}
+
+	if err := h.chatUsecase.UnbanUser(c.Param("roomID"), userID, targetID); err != nil {
+		respondError(c, err, "Unable to unban user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unbanned"})
+}
+
+// MuteUser godoc
+// @Summary Mute a user in a room
+// @Description Keep a user from posting in the room for a duration of up to 30 days. Muted users can still read. Only moderators and above may mute, and only members ranked below them.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param userID path int true "User ID"
+// @Param request body ModerationRequest true "Duration and reason"
+// @Success 200 {object} domain.RoomMute
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/mutes/{userID} [put]
+func (h *WSHandler) MuteUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	targetID, ok := intParam(c, "userID")
+	if !ok {
+		return
+	}
+	req, ok := bindModeration(c)
+	if !ok {
+		return
+	}
+
+	duration, err := moderationDuration(req.Duration)
+	if err != nil {
+		respondError(c, err, "Unable to mute user")
+		return
+	}
+	mute, err := h.chatUsecase.MuteUser(c.Param("roomID"), userID, targetID, duration, req.Reason)
+	if err != nil {
+		respondError(c, err, "Unable to mute user")
+		return
+	}
+
+	c.JSON(http.StatusOK, mute)
+}
+
+// UnmuteUser godoc
+// @Summary Unmute a user
+// @Description Let a muted user post in the room again. Only moderators and above may unmute.
+// @Tags moderation
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param userID path int true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/mutes/{userID} [delete]
+func (h *WSHandler) UnmuteUser(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	targetID, ok := intParam(c, "userID")
+	if !ok {
+		return
+	}
+
+	if err := h.chatUsecase.UnmuteUser(c.Param("roomID"), userID, targetID); err != nil {
+		respondError(c, err, "Unable to unmute user")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unmuted"})
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// PresenceRequest defines the request body for setting a status
+type PresenceRequest struct {
+	Status domain.PresenceStatus `json:"status"`
+}
+
+// GetRoomPresence godoc
+// @Summary Get the presence of a room's members
+// @Description List whether each member of the room is online, away, dnd or offline, with their number of connections
+// @Tags presence
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Success 200 {array} domain.Presence
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/presence [get]
+func (h *WSHandler) GetRoomPresence(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	presences, err := h.chatUsecase.GetRoomPresence(c.Param("roomID"), userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch presence")
+		return
+	}
+
+	c.JSON(http.StatusOK, presences)
+}
+
+// GetUserPresence godoc
+// @Summary Get the presence of a user
+// @Description Tell whether a user is online, away, dnd or offline, with their number of connections and when they were last seen
+// @Tags presence
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} domain.Presence
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{id}/presence [get]
+func (h *WSHandler) GetUserPresence(c *gin.Context) {
+	if _, ok := currentUserID(c); !ok {
+		return
+	}
+	targetID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	presence, err := h.chatUsecase.GetUserPresence(targetID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch presence")
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
+
+// SetPresenceStatus godoc
+// @Summary Set the caller's status
+// @Description Show the caller as online, away or dnd while connected. The change is announced to the caller's rooms.
+// @Tags presence
+// @Accept json
+// @Produce json
+// @Param request body PresenceRequest true "Status"
+// @Success 200 {object} domain.Presence
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/presence [put]
+func (h *WSHandler) SetPresenceStatus(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req PresenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	presence, err := h.chatUsecase.SetPresenceStatus(userID, req.Status)
+	if err != nil {
+		respondError(c, err, "Unable to set status")
+		return
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
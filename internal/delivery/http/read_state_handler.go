@@ -0,0 +1,78 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReadRequest defines the request body for marking a room as read. Both
+// fields are optional; the room is read up to its latest message when they
+// are omitted.
+type ReadRequest struct {
+	Seq       int64 `json:"seq"`
+	MessageID int   `json:"message_id"`
+}
+
+// MarkRead godoc
+// @Summary Mark a room as read
+// @Description Move the caller's read position forward to the message with the given sequence number or ID, or to the latest message. The position never moves backwards. The room is sent a read frame when it advances.
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param request body ReadRequest false "Read position"
+// @Success 200 {object} domain.ReadState
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/read [post]
+func (h *WSHandler) MarkRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var req ReadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+	}
+
+	state, err := h.chatUsecase.MarkRead(c.Param("roomID"), userID, req.Seq, req.MessageID)
+	if err != nil {
+		respondError(c, err, "Unable to mark room as read")
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// GetReadStates godoc
+// @Summary List how far the members of a room have read it
+// @Description List the read position of every member who has read the room, furthest first, so that clients can show who has seen a message
+// @Tags rooms
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Success 200 {array} domain.ReadState
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/read [get]
+func (h *WSHandler) GetReadStates(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	states, err := h.chatUsecase.GetReadStates(c.Param("roomID"), userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch read states")
+		return
+	}
+
+	c.JSON(http.StatusOK, states)
+}
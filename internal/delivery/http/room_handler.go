@@ -0,0 +1,128 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// JoinRoom godoc
+// @Summary Join a room
+// @Description Become a member of a public room. Private rooms cannot be joined directly.
+// @Tags rooms
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/join [post]
+func (h *WSHandler) JoinRoom(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.chatUsecase.JoinRoom(c.Param("roomID"), userID); err != nil {
+		respondError(c, err, "Unable to join room")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Joined room"})
+}
+
+// LeaveRoom godoc
+// @Summary Leave a room
+// @Description Stop being a member of a room. Leaving a private room revokes access to it. The owner must transfer ownership first.
+// @Tags rooms
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/leave [post]
+func (h *WSHandler) LeaveRoom(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.chatUsecase.LeaveRoom(c.Param("roomID"), userID); err != nil {
+		respondError(c, err, "Unable to leave room")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left room"})
+}
+
+// RoleRequest defines the request body for assigning a role
+type RoleRequest struct {
+	Role domain.Role `json:"role"`
+}
+
+// GetRoomMembers godoc
+// @Summary List the members of a room
+// @Description List the members of a room with their roles
+// @Tags rooms
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Success 200 {array} domain.RoomMember
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/members [get]
+func (h *WSHandler) GetRoomMembers(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.chatUsecase.GetRoomMembers(c.Param("roomID"), userID)
+	if err != nil {
+		respondError(c, err, "Unable to fetch members")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// SetMemberRole godoc
+// @Summary Assign a role to a member
+// @Description Make a member owner, admin, moderator or member. Owners and admins may assign roles below their own to members ranked below them; assigning owner transfers ownership.
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param roomID path string true "Room ID"
+// @Param userID path int true "User ID"
+// @Param request body RoleRequest true "New role"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /rooms/{roomID}/members/{userID}/role [put]
+func (h *WSHandler) SetMemberRole(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	targetID, ok := intParam(c, "userID")
+	if !ok {
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if err := h.chatUsecase.SetMemberRole(c.Param("roomID"), userID, targetID, req.Role); err != nil {
+		respondError(c, err, "Unable to assign role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
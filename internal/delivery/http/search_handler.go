@@ -0,0 +1,89 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// SearchMessages godoc
+// @Summary Search messages
+// @Description Full-text search of the messages in the rooms the caller may read, best matches first. The query uses web search syntax: quoted phrases, "or" and -excluded words. Each hit carries an HTML-escaped snippet with the matches wrapped in <mark> tags.
+// @Tags messages
+// @Produce json
+// @Param q query string true "Search query"
+// @Param room_id query int false "Only messages of this room"
+// @Param user_id query int false "Only messages posted by this user"
+// @Param from query string false "Only messages posted at or after this time (RFC 3339)"
+// @Param to query string false "Only messages posted before this time (RFC 3339)"
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param offset query int false "Hits to skip, from next_offset (max 1000)"
+// @Success 200 {object} domain.SearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /search/messages [get]
+func (h *WSHandler) SearchMessages(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	q, err := searchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search parameters"})
+		return
+	}
+
+	result, err := h.chatUsecase.SearchMessages(userID, q)
+	if err != nil {
+		respondError(c, err, "Unable to search messages")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// searchQuery parses the query parameters of a message search
+func searchQuery(c *gin.Context) (domain.SearchQuery, error) {
+	q := domain.SearchQuery{Text: c.Query("q"), RoomID: c.Query("room_id")}
+
+	if q.RoomID != "" {
+		if _, err := strconv.Atoi(q.RoomID); err != nil {
+			return q, fmt.Errorf("invalid room_id %q: %w", q.RoomID, domain.ErrInvalidInput)
+		}
+	}
+
+	ints := map[string]*int{"user_id": &q.UserID, "limit": &q.Limit, "offset": &q.Offset}
+	for name, dest := range ints {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid %s %q: %w", name, value, domain.ErrInvalidInput)
+		}
+		*dest = n
+	}
+
+	times := map[string]**time.Time{"from": &q.From, "to": &q.To}
+	for name, dest := range times {
+		value := c.Query(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return q, fmt.Errorf("invalid %s %q: %w", name, value, domain.ErrInvalidInput)
+		}
+		*dest = &t
+	}
+
+	return q, nil
+}
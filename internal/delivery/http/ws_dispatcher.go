@@ -0,0 +1,266 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/usecase"
+	"golang.org/x/time/rate"
+)
+
+// wsSession holds the state of a single WebSocket connection
+type wsSession struct {
+	client  *usecase.Client
+	userID  int
+	roomID  string
+	limiter *rate.Limiter // Shared by the user's connections to the room
+}
+
+// reply queues a frame for the client that owns the session
+func (s *wsSession) reply(frame domain.Frame) {
+	s.client.SendFrame(frame)
+}
+
+// replyError writes an error frame describing err back to the client.
+// Rate limit errors tell the client when to retry.
+func (s *wsSession) replyError(id string, err error) {
+	code, message := frameError(err)
+
+	payload := domain.ErrorPayload{Code: code, Message: message}
+	var rateLimit *domain.RateLimitError
+	if errors.As(err, &rateLimit) {
+		payload.RetryAfterMs = rateLimit.RetryAfter.Milliseconds()
+	}
+
+	frame, _ := domain.NewFrame(domain.FrameError, id, payload)
+	s.reply(frame)
+}
+
+// ack confirms to the client that the frame with the given ID was accepted
+func (s *wsSession) ack(id string, payload domain.AckPayload) {
+	frame, _ := domain.NewFrame(domain.FrameAck, id, payload)
+	s.reply(frame)
+}
+
+// frameHandler handles a single type of inbound frame
+type frameHandler func(s *wsSession, frame domain.Frame) error
+
+// registerFrameHandlers maps every inbound frame type to its handler
+func (h *WSHandler) registerFrameHandlers() {
+	h.handlers = map[domain.FrameType]frameHandler{
+		domain.FrameSend:   h.handleSend,
+		domain.FrameAck:    h.handleAck,
+		domain.FrameEdit:   h.handleEdit,
+		domain.FrameDelete: h.handleDelete,
+		domain.FrameRead:   h.handleRead,
+
+		domain.FrameTypingStart: h.handleTyping,
+		domain.FrameTypingStop:  h.handleTyping,
+
+		domain.FrameReactionAdd: h.handleReaction,
+		domain.FrameReactionDel: h.handleReaction,
+
+		domain.FrameKick: h.handleModeration,
+		domain.FrameBan:  h.handleModeration,
+		domain.FrameMute: h.handleModeration,
+	}
+}
+
+// dispatch decodes a raw frame and routes it to the handler for its type
+func (h *WSHandler) dispatch(s *wsSession, raw []byte) {
+	var frame domain.Frame
+	if err := json.Unmarshal(raw, &frame); err != nil {
+		s.reply(domain.NewErrorFrame("", domain.ErrCodeBadRequest, "Frame is not a valid JSON envelope"))
+		return
+	}
+
+	if frame.Version != 0 && frame.Version != domain.ProtocolVersion {
+		s.reply(domain.NewErrorFrame(frame.ID, domain.ErrCodeVersionMismatch, "Unsupported protocol version"))
+		return
+	}
+
+	handler, exists := h.handlers[frame.Type]
+	if !exists {
+		s.reply(domain.NewErrorFrame(frame.ID, domain.ErrCodeUnsupported, "Unsupported frame type"))
+		return
+	}
+
+	// Acks are not throttled, clients must send one for every frame they receive
+	if frame.Type != domain.FrameAck {
+		if err := usecase.Throttle(s.limiter); err != nil {
+			s.replyError(frame.ID, err)
+			return
+		}
+	}
+
+	if err := handler(s, frame); err != nil {
+		log.Printf("Error handling %s frame from user %d: %v", frame.Type, s.userID, err)
+		s.replyError(frame.ID, err)
+	}
+}
+
+// handleSend posts a new message to the session's room
+func (h *WSHandler) handleSend(s *wsSession, frame domain.Frame) error {
+	var payload domain.SendPayload
+	if err := decodePayload(frame, &payload); err != nil {
+		return err
+	}
+
+	// Create a message object, with the userID extracted from the token
+	msg := domain.Message{
+		UserID:      s.userID,
+		RoomID:      s.roomID,
+		Message:     payload.Message,
+		Timestamp:   time.Now(),
+		ParentID:    payload.ParentID,
+		ClientMsgID: payload.ClientMsgID,
+	}
+
+	// Only the IDs are known here, the usecase loads and checks the uploads
+	for _, id := range payload.AttachmentIDs {
+		msg.Attachments = append(msg.Attachments, domain.Attachment{ID: id})
+	}
+
+	// Send the message to the worker pool and acknowledge once it is saved
+	return h.chatUsecase.SendMessageToRoom(msg, func(saved domain.Message, err error) {
+		if err != nil {
+			s.replyError(frame.ID, err)
+			return
+		}
+		s.ack(frame.ID, domain.AckPayload{MessageID: saved.ID, ClientMsgID: saved.ClientMsgID, Seq: saved.Seq})
+	})
+}
+
+// handleAck records the delivery acks sent by the client
+func (h *WSHandler) handleAck(s *wsSession, frame domain.Frame) error {
+	var payload domain.AckPayload
+	if err := decodePayload(frame, &payload); err != nil {
+		return err
+	}
+
+	if payload.Seq != 0 {
+		s.client.Ack(payload.Seq)
+	}
+	s.client.Ack(payload.Seqs...)
+	return nil
+}
+
+// handleEdit changes the text of one of the user's messages
+func (h *WSHandler) handleEdit(s *wsSession, frame domain.Frame) error {
+	var payload domain.EditPayload
+	if err := decodePayload(frame, &payload); err != nil {
+		return err
+	}
+
+	msg, err := h.chatUsecase.EditMessage(s.roomID, payload.MessageID, s.userID, payload.Message)
+	if err != nil {
+		return err
+	}
+
+	s.ack(frame.ID, domain.AckPayload{MessageID: msg.ID})
+	return nil
+}
+
+// handleDelete retracts one of the user's messages
+func (h *WSHandler) handleDelete(s *wsSession, frame domain.Frame) error {
+	var payload domain.DeletePayload
+	if err := decodePayload(frame, &payload); err != nil {
+		return err
+	}
+
+	msg, err := h.chatUsecase.DeleteMessage(s.roomID, payload.MessageID, s.userID)
+	if err != nil {
+		return err
+	}
+
+	s.ack(frame.ID, domain.AckPayload{MessageID: msg.ID})
+	return nil
+}
+
+// handleRead moves the user's read position in the session's room forward
+func (h *WSHandler) handleRead(s *wsSession, frame domain.Frame) error {
+	var payload domain.ReadPayload
+	if len(frame.Payload) != 0 {
+		if err := decodePayload(frame, &payload); err != nil {
+			return err
+		}
+	}
+
+	state, err := h.chatUsecase.MarkRead(s.roomID, s.userID, payload.Seq, payload.MessageID)
+	if err != nil {
+		return err
+	}
+
+	s.ack(frame.ID, domain.AckPayload{Seq: state.LastReadSeq})
+	return nil
+}
+
+// handleTyping starts or stops the user's typing indicator. Typing frames are
+// not acknowledged.
+func (h *WSHandler) handleTyping(s *wsSession, frame domain.Frame) error {
+	if frame.Type == domain.FrameTypingStop {
+		h.chatUsecase.StopTyping(s.roomID, s.userID)
+		return nil
+	}
+	return h.chatUsecase.StartTyping(s.roomID, s.userID)
+}
+
+// handleReaction adds or removes one of the user's reactions on a message
+func (h *WSHandler) handleReaction(s *wsSession, frame domain.Frame) error {
+	var payload domain.ReactionPayload
+	if err := decodePayload(frame, &payload); err != nil {
+		return err
+	}
+
+	react := h.chatUsecase.AddReaction
+	if frame.Type == domain.FrameReactionDel {
+		react = h.chatUsecase.RemoveReaction
+	}
+	if _, err := react(s.roomID, payload.MessageID, s.userID, payload.Emoji); err != nil {
+		return err
+	}
+
+	s.ack(frame.ID, domain.AckPayload{MessageID: payload.MessageID})
+	return nil
+}
+
+// handleModeration kicks, bans or mutes a user of the session's room
+func (h *WSHandler) handleModeration(s *wsSession, frame domain.Frame) error {
+	var payload domain.ModerationPayload
+	if err := decodePayload(frame, &payload); err != nil {
+		return err
+	}
+
+	duration, err := moderationDuration(payload.Duration)
+	if err != nil {
+		return err
+	}
+	switch frame.Type {
+	case domain.FrameKick:
+		err = h.chatUsecase.KickUser(s.roomID, s.userID, payload.UserID, payload.Reason)
+	case domain.FrameBan:
+		_, err = h.chatUsecase.BanUser(s.roomID, s.userID, payload.UserID, duration, payload.Reason)
+	case domain.FrameMute:
+		_, err = h.chatUsecase.MuteUser(s.roomID, s.userID, payload.UserID, duration, payload.Reason)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.ack(frame.ID, domain.AckPayload{})
+	return nil
+}
+
+// decodePayload unmarshals the frame payload into v
+func decodePayload(frame domain.Frame, v interface{}) error {
+	if len(frame.Payload) == 0 {
+		return domain.ErrInvalidInput
+	}
+	if err := json.Unmarshal(frame.Payload, v); err != nil {
+		return domain.ErrInvalidInput
+	}
+	return nil
+}
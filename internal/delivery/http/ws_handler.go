@@ -1,11 +1,11 @@
 package http
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -27,22 +27,32 @@ var upgrader = websocket.Upgrader{
 }
 
 type WSHandler struct {
-	chatUsecase usecase.ChatUsecaseInterface
-	redisClient redis_interface.RedisClientInterface
+	chatUsecase  usecase.ChatUsecaseInterface
+	redisClient  redis_interface.RedisClientInterface
+	clientConfig usecase.ClientConfig
+	frameLimiter *usecase.FrameLimiter
+	handlers     map[domain.FrameType]frameHandler
 }
 
 func NewWSHandler(
   chatUsecase usecase.ChatUsecaseInterface,
   redisClient redis_interface.RedisClientInterface,
+  clientConfig usecase.ClientConfig,
+  frameLimiter *usecase.FrameLimiter,
 ) *WSHandler {
-	return &WSHandler{
-		chatUsecase: chatUsecase,
-		redisClient: redisClient,
+	h := &WSHandler{
+		chatUsecase:  chatUsecase,
+		redisClient:  redisClient,
+		clientConfig: clientConfig,
+		frameLimiter: frameLimiter,
 	}
+	h.registerFrameHandlers()
+	return h
 }
 
 type CreateRoomRequest struct {
-	RoomName string `json:"room_name"`
+	RoomName   string `json:"room_name"`
+	Visibility string `json:"visibility"` // public (default) or private
 }
 
 // WebSocketHandler godoc
@@ -50,10 +60,13 @@ type CreateRoomRequest struct {
 // @Description Connect to a WebSocket for real-time communication in a room
 // @Tags websocket
 // @Param roomID path string true "Room ID"
+// @Param since query int false "Replay messages with a sequence number greater than this before going live"
+// @Param acks query bool false "Require the client to acknowledge every message frame"
 // @Produce json
 // @Success 101 {string} string "WebSocket Connection Established"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Router /ws/{roomID} [get]
 func (h *WSHandler) WebSocketHandler(c *gin.Context) {
@@ -88,6 +101,29 @@ func (h *WSHandler) WebSocketHandler(c *gin.Context) {
 		return
 	}
 
+	// A reconnecting client passes the last sequence number it saw
+	var since int64
+	resume := c.Query("since") != ""
+	if resume {
+		since, err = strconv.ParseInt(c.Query("since"), 10, 64)
+		if err != nil || since < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid since parameter"})
+			return
+		}
+	}
+
+	// Clients asking for acks get at-least-once delivery of message frames
+	requireAcks, _ := strconv.ParseBool(c.Query("acks"))
+
+	roomID := c.Param("roomID") // Room ID from URL params
+
+	// Check that the room exists and that the user may join it before upgrading
+	if _, err := h.chatUsecase.CheckRoomAccess(roomID, userID); err != nil {
+		log.Printf("User %d may not connect to room %s: %v", userID, roomID, err)
+		respondError(c, err, "Unable to connect to room")
+		return
+	}
+
 	// Upgrade HTTP connection to WebSocket
 	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
@@ -96,66 +132,77 @@ func (h *WSHandler) WebSocketHandler(c *gin.Context) {
 	}
 	defer ws.Close()
 
-	roomID := c.Param("roomID") // Room ID from URL params
+	// Room is accessible, continue to handle messages
+	log.Printf("User %d connected to room %s", userID, roomID)
 
-	// Check if the room exists in the database
-	room, err := h.chatUsecase.GetRoomByID(roomID)
-	if err != nil || room == nil {
-		log.Printf("Room %s does not exist", roomID)
-		ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "Room does not exist"))
-		return
+	// Wrap the connection in a client that owns all writes to it
+	client := usecase.NewClient(ws, userID, roomID, h.clientConfig)
+	if requireAcks {
+		client.RequireAcks()
 	}
+	go client.WritePump()
+	defer client.Close()
 
-	// Room exists, continue to handle messages
-	log.Printf("User %d connected to room %s", userID, roomID)
+	// Count the connection towards the user's presence until it closes
+	h.chatUsecase.TrackPresence(client)
 
-	// Add WebSocket connection to the room
-	h.chatUsecase.AddClientToRoom(roomID, ws)
+	// Park live frames while missed messages are replayed
+	if resume {
+		client.HoldLive()
+	}
 
-	done := make(chan bool)
+	// Add the client to the room; this also subscribes the instance to the
+	// room's broadcasts if it is the first local client
+	if err := h.chatUsecase.AddClientToRoom(roomID, client); err != nil {
+		log.Printf("Error adding user %d to room %s: %v", userID, roomID, err)
+		client.CloseWithReason(websocket.CloseTryAgainLater, "Unable to subscribe to room")
+		return
+	}
 
-	// Start broadcasting messages for the room
-	go h.chatUsecase.BroadcastMessages(roomID, done)
+	if resume {
+		if err := h.chatUsecase.ResumeClient(client, since); err != nil {
+			log.Printf("Error resuming user %d in room %s: %v", userID, roomID, err)
+			client.CloseWithReason(websocket.CloseInternalServerErr, "Unable to replay missed messages")
+		}
+	}
 
-	// Handle incoming messages
+	// Throttle the frames the user sends to the room
+	limiter := h.frameLimiter.Acquire(roomID, userID)
+	defer h.frameLimiter.Release(roomID, userID)
+
+	session := &wsSession{client: client, userID: userID, roomID: roomID, limiter: limiter}
+
+	// Handle incoming frames
 	for {
-		_, message, err := ws.ReadMessage()
+		message, err := client.ReadMessage()
 		if err != nil {
 			log.Printf("Error reading message: %v", err)
 			break
 		}
 
-		// Create a message object, with the userID extracted from the token
-		msg := domain.Message{
-			UserID:    userID,
-			RoomID:    roomID,
-			Message:   string(message),
-			Timestamp: time.Now(),
-		}
-
-		// Send the message to the worker pool
-		if err := h.chatUsecase.SendMessageToRoom(msg); err != nil {
-			log.Printf("Error sending message: %v", err)
-		}
+		h.dispatch(session, message)
 	}
 
-	// Remove WebSocket connection from the room
-	h.chatUsecase.RemoveClientFromRoom(roomID, ws)
-
-	// Close the room when the connection is closed
-	h.chatUsecase.CloseRoom(roomID, done)
+	// Remove the client from the room and clear its typing indicator
+	h.chatUsecase.RemoveClientFromRoom(roomID, client)
+	h.chatUsecase.StopTyping(roomID, userID)
 }
 
 // GetRooms godoc
 // @Summary Get a list of available chat rooms
-// @Description Retrieve all available rooms for users to join
+// @Description Retrieve all public rooms and the private rooms the user is a member of, with the number of unread messages and mentions in the rooms the user is a member of
 // @Tags rooms
 // @Produce  json
-// @Success 200 {array} domain.Room
+// @Success 200 {array} domain.RoomSummary
 // @Failure 500 {object} map[string]string
 // @Router /rooms [get]
 func (h *WSHandler) GetRooms(c *gin.Context) {
-  rooms, err := h.chatUsecase.GetAvailableRooms()
+  userID, ok := currentUserID(c)
+  if !ok {
+      return
+  }
+
+  rooms, err := h.chatUsecase.GetAvailableRooms(userID)
   if err != nil {
       c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch rooms"})
       return
@@ -165,7 +212,7 @@ func (h *WSHandler) GetRooms(c *gin.Context) {
 
 // CreateRoom godoc
 // @Summary Create a new chat room
-// @Description Create a room for users to join
+// @Description Create a public or private room. The creator becomes its first member.
 // @Tags rooms
 // @Accept json
 // @Produce json
@@ -175,10 +222,13 @@ func (h *WSHandler) GetRooms(c *gin.Context) {
 // @Failure 500 {object} map[string]string
 // @Router /rooms [post]
 func (h *WSHandler) CreateRoom(c *gin.Context) {
-  var req struct {
-      RoomName string `json:"room_name"`
+  userID, ok := currentUserID(c)
+  if !ok {
+      return
   }
 
+  var req CreateRoomRequest
+
   if err := c.ShouldBindJSON(&req); err != nil || req.RoomName == "" {
       c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid room data"})
       return
@@ -186,12 +236,13 @@ func (h *WSHandler) CreateRoom(c *gin.Context) {
 
   // Create the room object (without the ID, which will be auto-generated)
   room := &domain.Room{
-      RoomName: req.RoomName,
+      RoomName:   req.RoomName,
+      Visibility: req.Visibility,
   }
 
   // Create the room in the database
-  if err := h.chatUsecase.CreateRoom(room); err != nil {
-      c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to create room"})
+  if err := h.chatUsecase.CreateRoom(room, userID); err != nil {
+      respondError(c, err, "Unable to create room")
       return
   }
 
@@ -200,22 +251,62 @@ func (h *WSHandler) CreateRoom(c *gin.Context) {
 
 // GetRoomMessages godoc
 // @Summary Get messages from a specific chat room
-// @Description Fetch the last 50 messages from a specified room
+// @Description Fetch a page of messages from a specified room, newest first, with aggregated reactions. Use next_cursor as `before` to page back in history and prev_cursor as `after` to page forward.
 // @Tags messages
 // @Produce  json
 // @Param roomID path string true "Room ID"
-// @Success 200 {array} domain.Message
+// @Param before query string false "Return messages older than this cursor"
+// @Param after query string false "Return messages newer than this cursor"
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Success 200 {object} domain.MessagePage
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /rooms/{roomID}/messages [get]
 func (h *WSHandler) GetRoomMessages(c *gin.Context) {
   roomID := c.Param("roomID")
 
-  // Fetch last 50 messages for the room
-  messages, err := h.chatUsecase.GetMessagesByRoom(roomID, 50)
+  userID, ok := currentUserID(c)
+  if !ok {
+      return
+  }
+
+  page, err := pageRequest(c)
   if err != nil {
-      c.JSON(http.StatusInternalServerError, gin.H{"error": "Unable to fetch messages"})
+      c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pagination parameters"})
+      return
+  }
+
+  messages, err := h.chatUsecase.GetMessagesByRoom(roomID, userID, page)
+  if err != nil {
+      respondError(c, err, "Unable to fetch messages")
       return
   }
 
   c.JSON(http.StatusOK, messages)
 }
+
+// pageRequest parses the before, after and limit query parameters
+func pageRequest(c *gin.Context) (domain.PageRequest, error) {
+  var page domain.PageRequest
+  var err error
+
+  if before := c.Query("before"); before != "" {
+      if page.Before, err = domain.DecodeCursor(before); err != nil {
+          return page, err
+      }
+  }
+  if after := c.Query("after"); after != "" {
+      if page.After, err = domain.DecodeCursor(after); err != nil {
+          return page, err
+      }
+  }
+  if limit := c.Query("limit"); limit != "" {
+      if page.Limit, err = strconv.Atoi(limit); err != nil || page.Limit <= 0 {
+          return page, fmt.Errorf("invalid limit %q: %w", limit, domain.ErrInvalidInput)
+      }
+  }
+
+  return page, nil
+}
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// Attachment is a file uploaded to a room. It belongs to no message until it
+// is sent with one. URL is a signed download link valid until URLExpiresAt.
+// Images get their dimensions and a thumbnail once they have been processed
+// in the background.
+type Attachment struct {
+	ID           int        `json:"id"`
+	RoomID       string     `json:"room_id"`
+	UserID       int        `json:"user_id"`
+	MessageID    *int       `json:"message_id,omitempty"`
+	Filename     string     `json:"filename"`
+	ContentType  string     `json:"content_type"`
+	Size         int64      `json:"size"`
+	StorageKey   string     `json:"-"`
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	Thumbnail    *Thumbnail `json:"thumbnail,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	URL          string     `json:"url,omitempty"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty"`
+}
+
+// Thumbnail is a small preview of an image attachment. URL is a signed link
+// valid as long as the attachment's.
+type Thumbnail struct {
+	StorageKey  string `json:"-"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	URL         string `json:"url,omitempty"`
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MessageCursor points at a message in a room's history. Messages are ordered
+// by timestamp, with the ID breaking ties between messages sent at once.
+type MessageCursor struct {
+	Timestamp time.Time
+	ID        int
+}
+
+// CursorFor returns the cursor pointing at msg
+func CursorFor(msg Message) MessageCursor {
+	return MessageCursor{Timestamp: msg.Timestamp, ID: msg.ID}
+}
+
+// Encode returns the opaque token handed out to clients
+func (c MessageCursor) Encode() string {
+	raw := strconv.FormatInt(c.Timestamp.UnixNano(), 10) + ":" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a token produced by MessageCursor.Encode
+func DecodeCursor(token string) (*MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor: %w", ErrInvalidInput)
+	}
+
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, fmt.Errorf("malformed cursor: %w", ErrInvalidInput)
+	}
+
+	ts, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor timestamp: %w", ErrInvalidInput)
+	}
+	messageID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, fmt.Errorf("malformed cursor id: %w", ErrInvalidInput)
+	}
+
+	return &MessageCursor{Timestamp: time.Unix(0, ts).UTC(), ID: messageID}, nil
+}
+
+// PageRequest selects a page of a room's history. At most one of Before and
+// After is set; without either the newest messages are returned.
+type PageRequest struct {
+	Before *MessageCursor
+	After  *MessageCursor
+	Limit  int
+}
+
+// MessagePage is a page of messages, newest first. NextCursor fetches older
+// messages and PrevCursor newer ones; each is empty when there are none.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+}
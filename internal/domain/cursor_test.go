@@ -0,0 +1,42 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := domain.MessageCursor{
+		Timestamp: time.Date(2024, 10, 1, 12, 30, 0, 123456789, time.UTC),
+		ID:        42,
+	}
+
+	decoded, err := domain.DecodeCursor(cursor.Encode())
+
+	assert.NoError(t, err)
+	assert.True(t, cursor.Timestamp.Equal(decoded.Timestamp))
+	assert.Equal(t, cursor.ID, decoded.ID)
+}
+
+func TestDecodeCursorRejectsMalformedTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "Not base64", token: "!!!"},
+		{name: "Missing separator", token: "MTIzNDU"},
+		{name: "Bad timestamp", token: "YWJjOjQy"},
+		{name: "Bad id", token: "MTIzOmFiYw"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := domain.DecodeCursor(tt.token)
+			assert.True(t, errors.Is(err, domain.ErrInvalidInput))
+		})
+	}
+}
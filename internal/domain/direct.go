@@ -0,0 +1,15 @@
+package domain
+
+// Participant is the other user of a direct conversation
+type Participant struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// DirectConversation is a private room shared by exactly two users, as seen
+// by one of them
+type DirectConversation struct {
+	Room        Room        `json:"room"`
+	Participant Participant `json:"participant"`
+	LastMessage *Message    `json:"last_message,omitempty"`
+}
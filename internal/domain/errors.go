@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrInvalidInput is returned when a request carries missing or malformed data
+	ErrInvalidInput = errors.New("invalid input")
+	// ErrNotFound is returned when the requested entity does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden is returned when the user may not perform the action
+	ErrForbidden = errors.New("forbidden")
+	// ErrDuplicate is returned when an entity was already created by an earlier request
+	ErrDuplicate = errors.New("duplicate")
+	// ErrTooLarge is returned when an upload exceeds the size limit
+	ErrTooLarge = errors.New("too large")
+	// ErrUnsupportedType is returned when an upload is not of an allowed type
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
+// ErrRateLimited is returned when a user acts faster than the room allows
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError reports that an action was throttled and how long to wait
+// before retrying it. It matches ErrRateLimited.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProtocolVersion is the version of the WebSocket envelope spoken by the server
+const ProtocolVersion = 1
+
+// FrameType identifies the kind of payload carried by a Frame
+type FrameType string
+
+const (
+	FrameSend        FrameType = "message.send"
+	FrameMessage     FrameType = "message.new"
+	FrameAck         FrameType = "ack"
+	FrameError       FrameType = "error"
+	FrameTypingStart FrameType = "typing.start"
+	FrameTypingStop  FrameType = "typing.stop"
+	FrameEdit        FrameType = "message.edit"
+	FrameDelete      FrameType = "message.delete"
+	FrameSystem      FrameType = "system"
+	FrameThreadReply FrameType = "thread.reply"
+	FrameThread      FrameType = "thread.update"
+	FrameReactionAdd FrameType = "reaction.add"
+	FrameReactionDel FrameType = "reaction.remove"
+	FrameKick        FrameType = "moderation.kick"
+	FrameBan         FrameType = "moderation.ban"
+	FrameMute        FrameType = "moderation.mute"
+	FramePresence    FrameType = "presence"
+	FrameRead        FrameType = "read"
+	FrameMention     FrameType = "mention"
+	FrameAttachment  FrameType = "attachment.update"
+	FrameLinkPreview FrameType = "message.preview"
+)
+
+// Error codes returned in error frames
+const (
+	ErrCodeBadRequest      = "bad_request"
+	ErrCodeUnsupported     = "unsupported_type"
+	ErrCodeVersionMismatch = "version_mismatch"
+	ErrCodeNotFound        = "not_found"
+	ErrCodeForbidden       = "forbidden"
+	ErrCodeRateLimited     = "rate_limited"
+	ErrCodeInternal        = "internal_error"
+)
+
+// Frame is the envelope for every message exchanged over a WebSocket. Frames
+// announcing a new message carry the message's room sequence number in Seq.
+type Frame struct {
+	Version int             `json:"v"`
+	Type    FrameType       `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Seq     int64           `json:"seq,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// SendPayload is sent by clients to post a new message
+type SendPayload struct {
+	Message       string `json:"message"`
+	ParentID      *int   `json:"parent_id,omitempty"`
+	ClientMsgID   string `json:"client_msg_id,omitempty"`  // Makes retried sends idempotent
+	AttachmentIDs []int  `json:"attachment_ids,omitempty"` // Uploaded attachments to send with the message
+}
+
+// AckPayload confirms that the frame with the given ID was accepted. Acks of
+// sent messages echo the client message ID along with the server assigned ID
+// and sequence number. Clients acknowledge the frames they received by
+// sending acks with the sequence numbers in Seq or Seqs.
+type AckPayload struct {
+	MessageID   int     `json:"message_id,omitempty"`
+	ClientMsgID string  `json:"client_msg_id,omitempty"`
+	Seq         int64   `json:"seq,omitempty"`
+	Seqs        []int64 `json:"seqs,omitempty"`
+}
+
+// ErrorPayload describes why a frame was rejected. Rate limited frames tell
+// the client how many milliseconds to wait before retrying.
+type ErrorPayload struct {
+	Code         string `json:"code"`
+	Message      string `json:"message"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+// TypingPayload announces that a user started or stopped typing
+type TypingPayload struct {
+	UserID int    `json:"user_id"`
+	RoomID string `json:"room_id"`
+}
+
+// ReadPayload is sent by clients to mark the room as read up to a message,
+// given by its sequence number or ID, or up to the latest message when both
+// are omitted. Frames sent by the server tell who read the room and how far.
+type ReadPayload struct {
+	Seq       int64  `json:"seq,omitempty"`
+	MessageID int    `json:"message_id,omitempty"`
+	UserID    int    `json:"user_id,omitempty"`
+	RoomID    string `json:"room_id,omitempty"`
+}
+
+// EditPayload is sent by clients to change the text of a message
+type EditPayload struct {
+	MessageID int    `json:"message_id"`
+	Message   string `json:"message"`
+}
+
+// DeletePayload is sent by clients to retract a message
+type DeletePayload struct {
+	MessageID int `json:"message_id"`
+}
+
+// ThreadReplyPayload announces a reply together with the updated thread summary
+type ThreadReplyPayload struct {
+	ParentID    int        `json:"parent_id"`
+	ReplyCount  int        `json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+	Reply       Message    `json:"reply"`
+}
+
+// ThreadPayload carries the summary of a thread whose replies changed
+type ThreadPayload struct {
+	ParentID    int        `json:"parent_id"`
+	ReplyCount  int        `json:"reply_count"`
+	LastReplyAt *time.Time `json:"last_reply_at,omitempty"`
+}
+
+// ReactionPayload adds or removes a reaction. Frames sent by the server also
+// carry who reacted and the new number of reactions with that emoji.
+type ReactionPayload struct {
+	MessageID int    `json:"message_id"`
+	Emoji     string `json:"emoji"`
+	UserID    int    `json:"user_id,omitempty"`
+	Count     int    `json:"count"`
+}
+
+// ModerationPayload is sent by moderators to kick, ban or mute a user.
+// Duration is in seconds; bans without a duration are permanent.
+type ModerationPayload struct {
+	UserID   int    `json:"user_id"`
+	Duration int    `json:"duration,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// SystemPayload carries server generated notices for a room. Notices about a
+// member name the user they concern.
+type SystemPayload struct {
+	Event     string     `json:"event"`
+	Message   string     `json:"message"`
+	UserID    int        `json:"user_id,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Events announced in system frames
+const (
+	SystemResumeTruncated = "resume_truncated"
+	SystemMemberKicked    = "member_kicked"
+	SystemMemberBanned    = "member_banned"
+	SystemMemberUnbanned  = "member_unbanned"
+	SystemMemberMuted     = "member_muted"
+	SystemMemberUnmuted   = "member_unmuted"
+	SystemSlowMode        = "slow_mode"
+)
+
+// NewFrame builds a frame of the given type with the payload encoded as JSON
+func NewFrame(frameType FrameType, id string, payload interface{}) (Frame, error) {
+	frame := Frame{Version: ProtocolVersion, Type: frameType, ID: id}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return Frame{}, err
+		}
+		frame.Payload = raw
+	}
+	return frame, nil
+}
+
+// NewErrorFrame builds an error frame replying to the frame with the given ID
+func NewErrorFrame(id, code, message string) Frame {
+	frame, _ := NewFrame(FrameError, id, ErrorPayload{Code: code, Message: message})
+	return frame
+}
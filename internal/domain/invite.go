@@ -0,0 +1,33 @@
+package domain
+
+import "time"
+
+// Invite lets whoever holds its token join a room until it expires, runs
+// out of uses or is revoked
+type Invite struct {
+	ID        int        `json:"id"`
+	Token     string     `json:"token"`
+	RoomID    string     `json:"room_id"`
+	CreatedBy int        `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	MaxUses   *int       `json:"max_uses,omitempty"` // Unlimited when nil
+	Uses      int        `json:"uses"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Usable reports whether the invite can still be accepted at the given time
+func (i *Invite) Usable(now time.Time) bool {
+	if i.RevokedAt != nil || !now.Before(i.ExpiresAt) {
+		return false
+	}
+	return i.MaxUses == nil || i.Uses < *i.MaxUses
+}
+
+// InviteRedemption records a user who joined a room through an invite
+type InviteRedemption struct {
+	ID         int       `json:"id"`
+	InviteID   int       `json:"invite_id"`
+	UserID     int       `json:"user_id"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
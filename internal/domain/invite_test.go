@@ -0,0 +1,29 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInviteUsable(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	two := 2
+
+	valid := domain.Invite{ExpiresAt: now.Add(time.Hour), MaxUses: &two, Uses: 1}
+	assert.True(t, valid.Usable(now))
+
+	expired := domain.Invite{ExpiresAt: now}
+	assert.False(t, expired.Usable(now))
+
+	usedUp := domain.Invite{ExpiresAt: now.Add(time.Hour), MaxUses: &two, Uses: 2}
+	assert.False(t, usedUp.Usable(now))
+
+	revoked := domain.Invite{ExpiresAt: now.Add(time.Hour), RevokedAt: &now}
+	assert.False(t, revoked.Usable(now))
+
+	unlimited := domain.Invite{ExpiresAt: now.Add(time.Hour), Uses: 1000}
+	assert.True(t, unlimited.Usable(now))
+}
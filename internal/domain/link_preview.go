@@ -0,0 +1,20 @@
+package domain
+
+// LinkPreview is the summary of a web page linked from a message, made from
+// its OpenGraph tags. Link previews are attached to a message in the order
+// the links appear in its text.
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// LinkPreviewPayload announces the link previews of a message once its links
+// were fetched, replacing any previews it had
+type LinkPreviewPayload struct {
+	MessageID int           `json:"message_id"`
+	RoomID    string        `json:"room_id"`
+	Previews  []LinkPreview `json:"previews"`
+}
@@ -0,0 +1,20 @@
+package domain
+
+// MentionKind tells who a mention addresses
+type MentionKind string
+
+const (
+	MentionUser MentionKind = "user" // @username, a single member of the room
+	MentionRoom MentionKind = "room" // @room, every member of the room
+	MentionHere MentionKind = "here" // @here, the members of the room who are online
+)
+
+// Mention is a mention found in the text of a message. Offset and Length
+// locate the mention, including the @, in characters of the text.
+type Mention struct {
+	Kind     MentionKind `json:"kind"`
+	UserID   int         `json:"user_id,omitempty"`
+	Username string      `json:"username,omitempty"`
+	Offset   int         `json:"offset"`
+	Length   int         `json:"length"`
+}
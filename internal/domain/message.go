@@ -3,9 +3,43 @@ package domain
 import "time"
 
 type Message struct {
+    ID           int           `json:"id"`
+    Seq          int64         `json:"seq"`
+    ClientMsgID  string        `json:"client_msg_id,omitempty"`
+    UserID       int           `json:"user_id"`
+    RoomID       string        `json:"room_id"`
+    Message      string        `json:"message"`
+    Timestamp    time.Time     `json:"timestamp"`
+    EditedAt     *time.Time    `json:"edited_at,omitempty"`
+    DeletedAt    *time.Time    `json:"deleted_at,omitempty"`
+    ParentID     *int          `json:"parent_id,omitempty"`
+    ReplyCount   int           `json:"reply_count"`
+    LastReplyAt  *time.Time    `json:"last_reply_at,omitempty"`
+    Reactions    []Reaction    `json:"reactions,omitempty"`
+    Mentions     []Mention     `json:"mentions,omitempty"`
+    Attachments  []Attachment  `json:"attachments,omitempty"`
+    LinkPreviews []LinkPreview `json:"link_previews,omitempty"`
+}
+
+// Reaction is the number of users who reacted to a message with an emoji.
+// Reacted tells whether the user reading the message is one of them.
+type Reaction struct {
+    Emoji   string `json:"emoji"`
+    Count   int    `json:"count"`
+    Reacted bool   `json:"reacted"`
+}
+
+// MessageRevision keeps the body a message had before it was edited or deleted
+type MessageRevision struct {
     ID        int       `json:"id"`
+    MessageID int       `json:"message_id"`
     UserID    int       `json:"user_id"`
-    RoomID    string    `json:"room_id"`
     Message   string    `json:"message"`
-    Timestamp time.Time `json:"timestamp"`
+    CreatedAt time.Time `json:"created_at"`
+}
+
+// Thread is a top-level message together with its replies, oldest first
+type Thread struct {
+    Parent  Message   `json:"parent"`
+    Replies []Message `json:"replies"`
 }
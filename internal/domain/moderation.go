@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// RoomBan keeps a user out of a room until it expires or is lifted
+type RoomBan struct {
+	RoomID    string     `json:"room_id"`
+	UserID    int        `json:"user_id"`
+	BannedBy  int        `json:"banned_by"`
+	Reason    string     `json:"reason,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Permanent when nil
+}
+
+// RoomMute lets a user read a room but not post in it until it expires
+type RoomMute struct {
+	RoomID    string    `json:"room_id"`
+	UserID    int       `json:"user_id"`
+	MutedBy   int       `json:"muted_by"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
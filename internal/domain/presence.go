@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// PresenceStatus tells whether a user is around
+type PresenceStatus string
+
+const (
+	StatusOnline  PresenceStatus = "online"
+	StatusAway    PresenceStatus = "away"
+	StatusDND     PresenceStatus = "dnd"
+	StatusOffline PresenceStatus = "offline"
+)
+
+// Presence is the status of a user together with the number of WebSocket
+// connections they hold across all rooms and instances
+type Presence struct {
+	UserID      int            `json:"user_id"`
+	Status      PresenceStatus `json:"status"`
+	Connections int            `json:"connections"`
+	LastSeen    *time.Time     `json:"last_seen,omitempty"` // When the user last went offline
+}
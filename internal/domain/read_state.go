@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// ReadState is how far a user has read a room: every message with a sequence
+// number up to LastReadSeq has been seen
+type ReadState struct {
+	RoomID      string    `json:"room_id"`
+	UserID      int       `json:"user_id"`
+	LastReadSeq int64     `json:"last_read_seq"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UnreadCount is the number of messages posted by others to a room since a
+// user last read it, and how many of them mention the user
+type UnreadCount struct {
+	Unread   int `json:"unread_count"`
+	Mentions int `json:"mention_count"`
+}
+
+// RoomSummary is a room as listed to a user, with the user's unread counts
+type RoomSummary struct {
+	Room
+	UnreadCount
+}
@@ -2,8 +2,37 @@ package domain
 
 import "time"
 
+// Room visibilities. Anyone may read and post in public rooms, while private
+// rooms are restricted to their members.
+const (
+  RoomPublic  = "public"
+  RoomPrivate = "private"
+)
+
+// Role is the rank of a member within a room
+type Role string
+
+const (
+  RoleOwner     Role = "owner"
+  RoleAdmin     Role = "admin"
+  RoleModerator Role = "moderator"
+  RoleMember    Role = "member"
+)
+
 type Room struct {
-  ID        string    `json:"id"`
-  RoomName  string    `json:"room_name"`
-  CreatedAt time.Time `json:"created_at"`
+  ID         string    `json:"id"`
+  RoomName   string    `json:"room_name"`
+  Visibility string    `json:"visibility"`
+  Direct     bool      `json:"direct"`    // Direct rooms are private conversations between two users
+  SlowMode   int       `json:"slow_mode"` // Seconds members must wait between messages, 0 when off
+  CreatedBy  *int      `json:"created_by,omitempty"`
+  CreatedAt  time.Time `json:"created_at"`
+}
+
+// RoomMember is a user who joined a room
+type RoomMember struct {
+  RoomID   string    `json:"room_id"`
+  UserID   int       `json:"user_id"`
+  Role     Role      `json:"role"`
+  JoinedAt time.Time `json:"joined_at"`
 }
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// SearchQuery selects the messages matching a full-text search. Every filter
+// is optional.
+type SearchQuery struct {
+	Text   string
+	RoomID string     // Only messages of this room
+	UserID int        // Only messages posted by this user
+	From   *time.Time // Only messages posted at or after this time
+	To     *time.Time // Only messages posted before this time
+	Limit  int
+	Offset int
+}
+
+// SearchHit is a message matching a search. Snippet is an HTML-escaped
+// excerpt of the message with the matching words wrapped in <mark> tags.
+type SearchHit struct {
+	Message Message `json:"message"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// SearchResult is a page of hits, best first. NextOffset fetches the next
+// page and is omitted on the last one.
+type SearchResult struct {
+	Hits       []SearchHit `json:"hits"`
+	NextOffset *int        `json:"next_offset,omitempty"`
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+// attachmentColumns is the column list scanned by scanAttachment
+const attachmentColumns = `id, room_id, user_id, message_id, filename, content_type, size, storage_key,
+	COALESCE(width, 0), COALESCE(height, 0), COALESCE(thumbnail_key, ''), COALESCE(thumbnail_content_type, ''),
+	COALESCE(thumbnail_width, 0), COALESCE(thumbnail_height, 0), created_at`
+
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// scanAttachment reads a row selected with attachmentColumns
+func scanAttachment(row rowScanner) (*domain.Attachment, error) {
+	var a domain.Attachment
+	var thumb domain.Thumbnail
+	if err := row.Scan(&a.ID, &a.RoomID, &a.UserID, &a.MessageID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey,
+		&a.Width, &a.Height, &thumb.StorageKey, &thumb.ContentType, &thumb.Width, &thumb.Height, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	if thumb.StorageKey != "" {
+		a.Thumbnail = &thumb
+	}
+	return &a, nil
+}
+
+// CreateAttachment records an uploaded file and sets its generated ID
+func (r *AttachmentRepository) CreateAttachment(a *domain.Attachment) error {
+	query := `
+		INSERT INTO attachments (room_id, user_id, filename, content_type, size, storage_key)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRow(query, a.RoomID, a.UserID, a.Filename, a.ContentType, a.Size, a.StorageKey).Scan(&a.ID, &a.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating attachment %s: %w", a.Filename, err)
+	}
+	return nil
+}
+
+// GetAttachment retrieves an attachment. Attachments of deleted messages are
+// not found.
+func (r *AttachmentRepository) GetAttachment(attachmentID int) (*domain.Attachment, error) {
+	query := `
+		SELECT ` + attachmentColumns + `
+		FROM attachments a
+		WHERE id = $1
+		  AND NOT EXISTS (SELECT 1 FROM messages m WHERE m.id = a.message_id AND m.deleted_at IS NOT NULL)
+	`
+	a, err := scanAttachment(r.db.QueryRow(query, attachmentID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("attachment %d: %w", attachmentID, domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("error retrieving attachment %d: %w", attachmentID, err)
+	}
+	return a, nil
+}
+
+// GetAttachments retrieves the given attachments, in no particular order.
+// Unknown IDs are left out.
+func (r *AttachmentRepository) GetAttachments(attachmentIDs []int) ([]domain.Attachment, error) {
+	ids := make([]int64, len(attachmentIDs))
+	for i, id := range attachmentIDs {
+		ids[i] = int64(id)
+	}
+	return r.queryAttachments(`SELECT `+attachmentColumns+` FROM attachments WHERE id = ANY($1)`, pq.Array(ids))
+}
+
+// LinkAttachments attaches uploads of userID in a room to a message and
+// returns them, in upload order. Only uploads that are not attached to a
+// message yet are linked.
+func (r *AttachmentRepository) LinkAttachments(messageID, userID int, roomID string, attachmentIDs []int) ([]domain.Attachment, error) {
+	ids := make([]int64, len(attachmentIDs))
+	for i, id := range attachmentIDs {
+		ids[i] = int64(id)
+	}
+
+	query := `
+		WITH linked AS (
+			UPDATE attachments SET message_id = $1
+			WHERE id = ANY($2) AND user_id = $3 AND room_id = $4 AND message_id IS NULL
+			RETURNING *
+		)
+		SELECT ` + attachmentColumns + ` FROM linked ORDER BY id
+	`
+	attachments, err := r.queryAttachments(query, messageID, pq.Array(ids), userID, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("error attaching files to message %d: %w", messageID, err)
+	}
+	if len(attachments) != len(ids) {
+		return nil, fmt.Errorf("attached %d of %d files to message %d: %w", len(attachments), len(ids), messageID, domain.ErrDuplicate)
+	}
+	return attachments, nil
+}
+
+// SetImageInfo records the dimensions and thumbnail of a processed image and
+// returns the updated attachment
+func (r *AttachmentRepository) SetImageInfo(attachmentID, width, height int, thumb domain.Thumbnail) (*domain.Attachment, error) {
+	query := `
+		UPDATE attachments
+		SET width = $2, height = $3, thumbnail_key = $4, thumbnail_content_type = $5,
+		    thumbnail_width = $6, thumbnail_height = $7
+		WHERE id = $1
+		RETURNING ` + attachmentColumns
+	a, err := scanAttachment(r.db.QueryRow(query, attachmentID, width, height, thumb.StorageKey, thumb.ContentType, thumb.Width, thumb.Height))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("attachment %d: %w", attachmentID, domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("error recording image info of attachment %d: %w", attachmentID, err)
+	}
+	return a, nil
+}
+
+// AttachAttachments fills in the attachments of each message that was not
+// deleted, in upload order
+func (r *AttachmentRepository) AttachAttachments(messages []domain.Message) error {
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int]int, len(messages))
+	for i, msg := range messages {
+		if msg.DeletedAt != nil {
+			continue
+		}
+		ids = append(ids, int64(msg.ID))
+		index[msg.ID] = i
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	attachments, err := r.queryAttachments(`
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE message_id = ANY($1)
+		ORDER BY message_id, id
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		i := index[*a.MessageID]
+		messages[i].Attachments = append(messages[i].Attachments, a)
+	}
+	return nil
+}
+
+// queryAttachments runs a query selecting attachmentColumns
+func (r *AttachmentRepository) queryAttachments(query string, args ...interface{}) ([]domain.Attachment, error) {
+	attachments := []domain.Attachment{}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching attachments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning attachment: %w", err)
+		}
+		attachments = append(attachments, *a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return attachments, nil
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// inviteColumns is the column list scanned by scanInvite
+const inviteColumns = `id, token, room_id, COALESCE(created_by, 0), created_at, expires_at, max_uses, uses, revoked_at`
+
+type InviteRepository struct {
+	db *sql.DB
+}
+
+func NewInviteRepository(db *sql.DB) *InviteRepository {
+	return &InviteRepository{db: db}
+}
+
+// scanInvite reads a row selected with inviteColumns
+func scanInvite(row rowScanner) (*domain.Invite, error) {
+	var invite domain.Invite
+	var maxUses sql.NullInt64
+	var revokedAt sql.NullTime
+	if err := row.Scan(&invite.ID, &invite.Token, &invite.RoomID, &invite.CreatedBy, &invite.CreatedAt,
+		&invite.ExpiresAt, &maxUses, &invite.Uses, &revokedAt); err != nil {
+		return nil, err
+	}
+	if maxUses.Valid {
+		n := int(maxUses.Int64)
+		invite.MaxUses = &n
+	}
+	if revokedAt.Valid {
+		invite.RevokedAt = &revokedAt.Time
+	}
+	return &invite, nil
+}
+
+// CreateInvite stores a new invite and sets its generated ID
+func (r *InviteRepository) CreateInvite(invite *domain.Invite) error {
+	query := `
+		INSERT INTO room_invites (token, room_id, created_by, expires_at, max_uses)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRow(query, invite.Token, invite.RoomID, invite.CreatedBy, invite.ExpiresAt, invite.MaxUses).
+		Scan(&invite.ID, &invite.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating invite for room %s: %w", invite.RoomID, err)
+	}
+	return nil
+}
+
+// GetInvitesByRoom lists the invites of a room, newest first
+func (r *InviteRepository) GetInvitesByRoom(roomID string) ([]domain.Invite, error) {
+	invites := []domain.Invite{}
+	query := `
+		SELECT ` + inviteColumns + `
+		FROM room_invites
+		WHERE room_id = $1
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := r.db.Query(query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching invites for room %s: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning invite for room %s: %w", roomID, err)
+		}
+		invites = append(invites, *invite)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return invites, nil
+}
+
+// GetInviteByToken returns the invite with the given token
+func (r *InviteRepository) GetInviteByToken(token string) (*domain.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM room_invites WHERE token = $1`
+	invite, err := scanInvite(r.db.QueryRow(query, token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invite: %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching invite: %w", err)
+	}
+	return invite, nil
+}
+
+// RevokeInvite revokes an invite of a room. Revoking an invite twice keeps
+// the original revocation time.
+func (r *InviteRepository) RevokeInvite(roomID string, inviteID int) (*domain.Invite, error) {
+	query := `
+		UPDATE room_invites SET revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP)
+		WHERE id = $1 AND room_id = $2
+		RETURNING ` + inviteColumns
+	invite, err := scanInvite(r.db.QueryRow(query, inviteID, roomID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invite %d in room %s: %w", inviteID, roomID, domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("error revoking invite %d: %w", inviteID, err)
+	}
+	return invite, nil
+}
+
+// RedeemInvite adds userID to the room of the invite with the given token,
+// counts the use and records who joined. Members of the room keep their role
+// and do not use up the invite. Unknown, revoked, expired and used up invites
+// return ErrNotFound.
+func (r *InviteRepository) RedeemInvite(token string, userID int, now time.Time) (*domain.Invite, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction for invite: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the invite so that concurrent redemptions respect max_uses
+	query := `SELECT ` + inviteColumns + ` FROM room_invites WHERE token = $1 FOR UPDATE`
+	invite, err := scanInvite(tx.QueryRow(query, token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("invite: %w", domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("error fetching invite: %w", err)
+	}
+	if !invite.Usable(now) {
+		return nil, fmt.Errorf("invite %d is no longer valid: %w", invite.ID, domain.ErrNotFound)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO room_members (room_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, invite.RoomID, userID, domain.RoleMember)
+	if err != nil {
+		return nil, fmt.Errorf("error adding user %d to room %s: %w", userID, invite.RoomID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return invite, nil // Already a member
+	}
+
+	if _, err := tx.Exec(`UPDATE room_invites SET uses = uses + 1 WHERE id = $1`, invite.ID); err != nil {
+		return nil, fmt.Errorf("error counting use of invite %d: %w", invite.ID, err)
+	}
+	invite.Uses++
+
+	if _, err := tx.Exec(`INSERT INTO room_invite_redemptions (invite_id, user_id) VALUES ($1, $2)`, invite.ID, userID); err != nil {
+		return nil, fmt.Errorf("error recording use of invite %d: %w", invite.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing use of invite %d: %w", invite.ID, err)
+	}
+	return invite, nil
+}
+
+// GetRedemptions lists who joined through an invite of a room, oldest first
+func (r *InviteRepository) GetRedemptions(roomID string, inviteID int) ([]domain.InviteRedemption, error) {
+	redemptions := []domain.InviteRedemption{}
+	query := `
+		SELECT ir.id, ir.invite_id, ir.user_id, ir.redeemed_at
+		FROM room_invite_redemptions ir
+		JOIN room_invites i ON i.id = ir.invite_id
+		WHERE ir.invite_id = $1 AND i.room_id = $2
+		ORDER BY ir.redeemed_at, ir.id
+	`
+	rows, err := r.db.Query(query, inviteID, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching redemptions of invite %d: %w", inviteID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var redemption domain.InviteRedemption
+		if err := rows.Scan(&redemption.ID, &redemption.InviteID, &redemption.UserID, &redemption.RedeemedAt); err != nil {
+			return nil, fmt.Errorf("error scanning redemption of invite %d: %w", inviteID, err)
+		}
+		redemptions = append(redemptions, redemption)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return redemptions, nil
+}
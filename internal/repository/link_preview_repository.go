@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+type LinkPreviewRepository struct {
+	db *sql.DB
+}
+
+func NewLinkPreviewRepository(db *sql.DB) *LinkPreviewRepository {
+	return &LinkPreviewRepository{db: db}
+}
+
+// SaveLinkPreviews replaces the link previews of a message whose text is
+// still text. Messages that were deleted or edited in the meantime are not
+// found.
+func (r *LinkPreviewRepository) SaveLinkPreviews(messageID int, text string, previews []domain.LinkPreview) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var live bool
+	query := `SELECT deleted_at IS NULL AND message = $2 FROM messages WHERE id = $1 FOR SHARE`
+	err = tx.QueryRow(query, messageID, text).Scan(&live)
+	if err == sql.ErrNoRows || (err == nil && !live) {
+		return fmt.Errorf("message %d: %w", messageID, domain.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("error locking message %d: %w", messageID, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM message_link_previews WHERE message_id = $1`, messageID); err != nil {
+		return fmt.Errorf("error clearing link previews of message %d: %w", messageID, err)
+	}
+
+	insert := `
+		INSERT INTO message_link_previews (message_id, position, url, title, description, image_url, site_name)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	for i, p := range previews {
+		if _, err := tx.Exec(insert, messageID, i, p.URL, p.Title, p.Description, p.Image, p.SiteName); err != nil {
+			return fmt.Errorf("error saving link preview of message %d: %w", messageID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing link previews of message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// AttachLinkPreviews fills in the link previews of each message that was not
+// deleted
+func (r *LinkPreviewRepository) AttachLinkPreviews(messages []domain.Message) error {
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int]int, len(messages))
+	for i, msg := range messages {
+		if msg.DeletedAt != nil {
+			continue
+		}
+		ids = append(ids, int64(msg.ID))
+		index[msg.ID] = i
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT message_id, url, title, description, image_url, site_name
+		FROM message_link_previews
+		WHERE message_id = ANY($1)
+		ORDER BY message_id, position
+	`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error fetching link previews: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int
+		var p domain.LinkPreview
+		if err := rows.Scan(&messageID, &p.URL, &p.Title, &p.Description, &p.Image, &p.SiteName); err != nil {
+			return fmt.Errorf("error scanning link preview: %w", err)
+		}
+		i := index[messageID]
+		messages[i].LinkPreviews = append(messages[i].LinkPreviews, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return nil
+}
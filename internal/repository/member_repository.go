@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+type MemberRepository struct {
+	db *sql.DB
+}
+
+func NewMemberRepository(db *sql.DB) *MemberRepository {
+	return &MemberRepository{db: db}
+}
+
+// AddMember adds a user to a room with the given role. Adding an existing
+// member has no effect and keeps their role.
+func (r *MemberRepository) AddMember(roomID string, userID int, role domain.Role) error {
+	query := `
+		INSERT INTO room_members (room_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := r.db.Exec(query, roomID, userID, role); err != nil {
+		return fmt.Errorf("error adding user %d to room %s: %w", userID, roomID, err)
+	}
+	return nil
+}
+
+// RemoveMember removes a user from a room
+func (r *MemberRepository) RemoveMember(roomID string, userID int) error {
+	query := `DELETE FROM room_members WHERE room_id = $1 AND user_id = $2`
+	if _, err := r.db.Exec(query, roomID, userID); err != nil {
+		return fmt.Errorf("error removing user %d from room %s: %w", userID, roomID, err)
+	}
+	return nil
+}
+
+// IsMember reports whether a user belongs to a room
+func (r *MemberRepository) IsMember(roomID string, userID int) (bool, error) {
+	role, err := r.GetRole(roomID, userID)
+	if err != nil {
+		return false, err
+	}
+	return role != "", nil
+}
+
+// GetRole returns the role of a user in a room, or an empty role if the user
+// is not a member
+func (r *MemberRepository) GetRole(roomID string, userID int) (domain.Role, error) {
+	var role domain.Role
+	query := `SELECT role FROM room_members WHERE room_id = $1 AND user_id = $2`
+	err := r.db.QueryRow(query, roomID, userID).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("error fetching role of user %d in room %s: %w", userID, roomID, err)
+	}
+	return role, nil
+}
+
+// SetRole changes the role of a member
+func (r *MemberRepository) SetRole(roomID string, userID int, role domain.Role) error {
+	query := `UPDATE room_members SET role = $3 WHERE room_id = $1 AND user_id = $2`
+	res, err := r.db.Exec(query, roomID, userID, role)
+	if err != nil {
+		return fmt.Errorf("error setting role of user %d in room %s: %w", userID, roomID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d in room %s: %w", userID, roomID, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// TransferOwnership makes toID the owner of a room and demotes the current
+// owner fromID to admin
+func (r *MemberRepository) TransferOwnership(roomID string, fromID, toID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction for room %s: %w", roomID, err)
+	}
+	defer tx.Rollback()
+
+	// Demote first, a room has at most one owner
+	query := `UPDATE room_members SET role = $3 WHERE room_id = $1 AND user_id = $2 AND role = $4`
+	res, err := tx.Exec(query, roomID, fromID, domain.RoleAdmin, domain.RoleOwner)
+	if err != nil {
+		return fmt.Errorf("error demoting owner of room %s: %w", roomID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d does not own room %s: %w", fromID, roomID, domain.ErrForbidden)
+	}
+
+	query = `UPDATE room_members SET role = $3 WHERE room_id = $1 AND user_id = $2`
+	res, err = tx.Exec(query, roomID, toID, domain.RoleOwner)
+	if err != nil {
+		return fmt.Errorf("error promoting user %d in room %s: %w", toID, roomID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d in room %s: %w", toID, roomID, domain.ErrNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing ownership of room %s: %w", roomID, err)
+	}
+	return nil
+}
+
+// GetMembers lists the members of a room in the order they joined
+func (r *MemberRepository) GetMembers(roomID string) ([]domain.RoomMember, error) {
+	members := []domain.RoomMember{}
+	query := `
+		SELECT room_id, user_id, role, joined_at
+		FROM room_members
+		WHERE room_id = $1
+		ORDER BY joined_at, user_id
+	`
+	rows, err := r.db.Query(query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching members of room %s: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var member domain.RoomMember
+		if err := rows.Scan(&member.RoomID, &member.UserID, &member.Role, &member.JoinedAt); err != nil {
+			return nil, fmt.Errorf("error scanning member of room %s: %w", roomID, err)
+		}
+		members = append(members, member)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return members, nil
+}
+
+// GetRoomIDsByUser lists the rooms a user is a member of
+func (r *MemberRepository) GetRoomIDsByUser(userID int) ([]string, error) {
+	roomIDs := []string{}
+	rows, err := r.db.Query(`SELECT room_id FROM room_members WHERE user_id = $1 ORDER BY room_id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rooms of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var roomID string
+		if err := rows.Scan(&roomID); err != nil {
+			return nil, fmt.Errorf("error scanning room of user %d: %w", userID, err)
+		}
+		roomIDs = append(roomIDs, roomID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return roomIDs, nil
+}
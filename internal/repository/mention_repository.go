@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+type MentionRepository struct {
+	db *sql.DB
+}
+
+func NewMentionRepository(db *sql.DB) *MentionRepository {
+	return &MentionRepository{db: db}
+}
+
+// ResolveUsernames finds the members of a room with the given usernames,
+// ignoring case. The result maps each lowercased username to the member. When
+// several members share a username the one who registered first is used.
+func (r *MentionRepository) ResolveUsernames(roomID string, usernames []string) (map[string]domain.Participant, error) {
+	users := make(map[string]domain.Participant, len(usernames))
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	lowered := make([]string, len(usernames))
+	for i, name := range usernames {
+		lowered[i] = strings.ToLower(name)
+	}
+
+	query := `
+		SELECT DISTINCT ON (LOWER(u.username)) u.id, u.username
+		FROM users u
+		JOIN room_members m ON m.user_id = u.id AND m.room_id = $1
+		WHERE LOWER(u.username) = ANY($2)
+		ORDER BY LOWER(u.username), u.id
+	`
+	rows, err := r.db.Query(query, roomID, pq.Array(lowered))
+	if err != nil {
+		return nil, fmt.Errorf("error resolving mentions in room %s: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user domain.Participant
+		if err := rows.Scan(&user.UserID, &user.Username); err != nil {
+			return nil, fmt.Errorf("error scanning mentioned user: %w", err)
+		}
+		users[strings.ToLower(user.Username)] = user
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return users, nil
+}
+
+// SaveMentions replaces the mentions recorded for a message
+func (r *MentionRepository) SaveMentions(messageID int, mentions []domain.Mention) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction for mentions of message %d: %w", messageID, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM message_mentions WHERE message_id = $1`, messageID); err != nil {
+		return fmt.Errorf("error clearing mentions of message %d: %w", messageID, err)
+	}
+
+	insert := `
+		INSERT INTO message_mentions (message_id, kind, user_id, start_offset, length)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5)
+	`
+	for _, mention := range mentions {
+		if _, err := tx.Exec(insert, messageID, mention.Kind, mention.UserID, mention.Offset, mention.Length); err != nil {
+			return fmt.Errorf("error saving mention of message %d: %w", messageID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing mentions of message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// AttachMentions fills in the mentions of each message that was not deleted
+func (r *MentionRepository) AttachMentions(messages []domain.Message) error {
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int]int, len(messages))
+	for i, msg := range messages {
+		if msg.DeletedAt != nil {
+			continue
+		}
+		ids = append(ids, int64(msg.ID))
+		index[msg.ID] = i
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT mm.message_id, mm.kind, COALESCE(mm.user_id, 0), COALESCE(u.username, ''), mm.start_offset, mm.length
+		FROM message_mentions mm
+		LEFT JOIN users u ON u.id = mm.user_id
+		WHERE mm.message_id = ANY($1)
+		ORDER BY mm.message_id, mm.start_offset
+	`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error fetching mentions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int
+		var mention domain.Mention
+		if err := rows.Scan(&messageID, &mention.Kind, &mention.UserID, &mention.Username, &mention.Offset, &mention.Length); err != nil {
+			return fmt.Errorf("error scanning mention: %w", err)
+		}
+		i := index[messageID]
+		messages[i].Mentions = append(messages[i].Mentions, mention)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return nil
+}
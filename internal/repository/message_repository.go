@@ -5,8 +5,18 @@ import (
 	"fmt"
 
 	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/lib/pq"
 )
 
+// messageColumns is the column list scanned by scanMessage
+const messageColumns = `id, seq, user_id, room_id, message, timestamp, edited_at, deleted_at,
+	parent_id, reply_count, last_reply_at, COALESCE(client_msg_id, '')`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
 type MessageRepository struct {
 	db *sql.DB
 }
@@ -15,56 +25,401 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
-// SaveMessage inserts a message into the database
-func (r *MessageRepository) SaveMessage(msg domain.Message) error {
-  // Check if the room exists before saving the message
-  roomQuery := `SELECT COUNT(1) FROM rooms WHERE id = $1`
-  var roomCount int
-  err := r.db.QueryRow(roomQuery, msg.RoomID).Scan(&roomCount)
-  if err != nil || roomCount == 0 {
-      return fmt.Errorf("room %s does not exist", msg.RoomID)
-  }
+// scanMessage reads a row selected with messageColumns, followed by the
+// columns scanned into extra if any
+func scanMessage(row rowScanner, extra ...interface{}) (*domain.Message, error) {
+	var msg domain.Message
+	var editedAt, deletedAt, lastReplyAt sql.NullTime
+	var parentID sql.NullInt64
+	dest := []interface{}{&msg.ID, &msg.Seq, &msg.UserID, &msg.RoomID, &msg.Message, &msg.Timestamp, &editedAt, &deletedAt,
+		&parentID, &msg.ReplyCount, &lastReplyAt, &msg.ClientMsgID}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	if editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		msg.DeletedAt = &deletedAt.Time
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		msg.ParentID = &id
+	}
+	if lastReplyAt.Valid {
+		msg.LastReplyAt = &lastReplyAt.Time
+	}
+	return &msg, nil
+}
+
+// SaveMessage inserts a message into the database and sets its generated ID
+// and room sequence number. Saving a reply also bumps the reply count of its
+// parent. If the user already sent a message with the same client message ID
+// to the room, msg is replaced by that message and ErrDuplicate is returned.
+func (r *MessageRepository) SaveMessage(msg *domain.Message) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction for room %s: %w", msg.RoomID, err)
+	}
+	defer tx.Rollback()
+
+	// Take the next sequence number of the room. The row stays locked until
+	// commit, so messages of a room are committed in sequence order.
+	seqQuery := `UPDATE rooms SET last_seq = last_seq + 1 WHERE id = $1 RETURNING last_seq`
+	if err := tx.QueryRow(seqQuery, msg.RoomID).Scan(&msg.Seq); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("room %s does not exist", msg.RoomID)
+		}
+		return fmt.Errorf("error assigning sequence for room %s: %w", msg.RoomID, err)
+	}
+
+	// With the room locked, a retried send cannot race its original
+	if msg.ClientMsgID != "" {
+		existing, err := r.getByClientMsgID(tx, msg.UserID, msg.RoomID, msg.ClientMsgID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			*msg = *existing
+			return fmt.Errorf("message %s from user %d: %w", msg.ClientMsgID, msg.UserID, domain.ErrDuplicate)
+		}
+	}
 
 	query := `
-		INSERT INTO messages (user_id, room_id, message, timestamp)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO messages (user_id, room_id, message, timestamp, parent_id, seq, client_msg_id)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
 		RETURNING id
 	`
-	err = r.db.QueryRow(query, msg.UserID, msg.RoomID, msg.Message, msg.Timestamp).Scan(&msg.ID)
+	err = tx.QueryRow(query, msg.UserID, msg.RoomID, msg.Message, msg.Timestamp, msg.ParentID, msg.Seq, msg.ClientMsgID).Scan(&msg.ID)
 	if err != nil {
 		return fmt.Errorf("error saving message for room %s: %w", msg.RoomID, err)
 	}
+
+	if msg.ParentID != nil {
+		threadQuery := `
+			UPDATE messages
+			SET reply_count = reply_count + 1, last_reply_at = GREATEST(last_reply_at, $2)
+			WHERE id = $1
+		`
+		if _, err := tx.Exec(threadQuery, *msg.ParentID, msg.Timestamp); err != nil {
+			return fmt.Errorf("error updating thread %d: %w", *msg.ParentID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing message for room %s: %w", msg.RoomID, err)
+	}
+	return nil
+}
+
+// GetMessageByClientMsgID retrieves the message a user sent to a room with the
+// given client message ID, or nil if there is none
+func (r *MessageRepository) GetMessageByClientMsgID(userID int, roomID, clientMsgID string) (*domain.Message, error) {
+	return r.getByClientMsgID(r.db, userID, roomID, clientMsgID)
+}
+
+// queryRower is implemented by both *sql.DB and *sql.Tx
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (r *MessageRepository) getByClientMsgID(q queryRower, userID int, roomID, clientMsgID string) (*domain.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE user_id = $1 AND room_id = $2 AND client_msg_id = $3`
+
+	msg, err := scanMessage(q.QueryRow(query, userID, roomID, clientMsgID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error retrieving message %s from user %d: %w", clientMsgID, userID, err)
+	}
+	return msg, nil
+}
+
+// GetMessageByID retrieves a single message, including deleted ones
+func (r *MessageRepository) GetMessageByID(messageID int) (*domain.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
+
+	msg, err := scanMessage(r.db.QueryRow(query, messageID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("message %d: %w", messageID, domain.ErrNotFound)
+		}
+		return nil, fmt.Errorf("error retrieving message %d: %w", messageID, err)
+	}
+	return msg, nil
+}
+
+// EditMessage replaces the text of a message, keeping the previous text as a revision
+func (r *MessageRepository) EditMessage(messageID, editorID int, text string) (*domain.Message, error) {
+	return r.reviseMessage(messageID, editorID, `
+		UPDATE messages
+		SET message = $2, edited_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+messageColumns, text)
+}
+
+// DeleteMessage soft-deletes a message, leaving a tombstone with an empty body.
+// The deleted text is kept as a revision. Deleting a reply recounts its thread.
+func (r *MessageRepository) DeleteMessage(messageID, editorID int) (*domain.Message, error) {
+	return r.reviseMessage(messageID, editorID, `
+		UPDATE messages
+		SET message = '', deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+messageColumns)
+}
+
+// reviseMessage stores the current body of a message as a revision and then
+// runs update, all within a single transaction
+func (r *MessageRepository) reviseMessage(messageID, editorID int, update string, args ...interface{}) (*domain.Message, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction for message %d: %w", messageID, err)
+	}
+	defer tx.Rollback()
+
+	insertRevision := `
+		INSERT INTO message_revisions (message_id, user_id, message)
+		SELECT id, $2, message FROM messages WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`
+	res, err := tx.Exec(insertRevision, messageID, editorID)
+	if err != nil {
+		return nil, fmt.Errorf("error saving revision of message %d: %w", messageID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("message %d: %w", messageID, domain.ErrNotFound)
+	}
+
+	msg, err := scanMessage(tx.QueryRow(update, append([]interface{}{messageID}, args...)...))
+	if err != nil {
+		return nil, fmt.Errorf("error updating message %d: %w", messageID, err)
+	}
+
+	if msg.DeletedAt != nil && msg.ParentID != nil {
+		if err := recountThread(tx, *msg.ParentID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing revision of message %d: %w", messageID, err)
+	}
+	return msg, nil
+}
+
+// recountThread recomputes the reply count and last reply time of a thread
+// from its live replies
+func recountThread(tx *sql.Tx, parentID int) error {
+	// Lock the parent first so that the count, taken by the next statement,
+	// includes every reply committed before it
+	if _, err := tx.Exec(`SELECT id FROM messages WHERE id = $1 FOR UPDATE`, parentID); err != nil {
+		return fmt.Errorf("error locking thread %d: %w", parentID, err)
+	}
+
+	query := `
+		UPDATE messages p
+		SET reply_count = r.count, last_reply_at = r.last_reply_at
+		FROM (
+			SELECT COUNT(*) AS count, MAX(timestamp) AS last_reply_at
+			FROM messages
+			WHERE parent_id = $1 AND deleted_at IS NULL
+		) r
+		WHERE p.id = $1
+	`
+	if _, err := tx.Exec(query, parentID); err != nil {
+		return fmt.Errorf("error recounting thread %d: %w", parentID, err)
+	}
 	return nil
 }
 
-// GetMessagesByRoom fetches the last 'limit' messages for a given room
-func (r *MessageRepository) GetMessagesByRoom(roomID string, limit int) ([]domain.Message, error) {
-	var messages []domain.Message
+// GetMessageRevisions returns the previous bodies of a message, oldest first
+func (r *MessageRepository) GetMessageRevisions(messageID int) ([]domain.MessageRevision, error) {
+	var revisions []domain.MessageRevision
 	query := `
-		SELECT id, user_id, room_id, message, timestamp
+		SELECT id, message_id, user_id, message, created_at
+		FROM message_revisions
+		WHERE message_id = $1
+		ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching revisions for message %d: %w", messageID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rev domain.MessageRevision
+		if err := rows.Scan(&rev.ID, &rev.MessageID, &rev.UserID, &rev.Message, &rev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning revision for message %d: %w", messageID, err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// GetThreadReplies fetches up to 'limit' live replies to a message, oldest first
+func (r *MessageRepository) GetThreadReplies(parentID int, limit int) ([]domain.Message, error) {
+	messages := []domain.Message{}
+	query := `
+		SELECT ` + messageColumns + `
 		FROM messages
-		WHERE room_id=$1
-		ORDER BY timestamp DESC
+		WHERE parent_id = $1 AND deleted_at IS NULL
+		ORDER BY timestamp, id
 		LIMIT $2
 	`
-	rows, err := r.db.Query(query, roomID, limit)
+	rows, err := r.db.Query(query, parentID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching replies to message %d: %w", parentID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning reply to message %d: %w", parentID, err)
+		}
+		messages = append(messages, *msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return messages, nil
+}
+
+// GetMessagesByRoomCursor fetches up to 'limit' top-level messages of a room
+// relative to a cursor, newest first. With before set it returns messages
+// older than the cursor, with after set messages newer than it, and with
+// neither the newest messages.
+func (r *MessageRepository) GetMessagesByRoomCursor(roomID string, before, after *domain.MessageCursor, limit int) ([]domain.Message, error) {
+	messages := []domain.Message{}
+	args := []interface{}{roomID, limit}
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE room_id = $1 AND parent_id IS NULL
+		ORDER BY timestamp DESC, id DESC
+		LIMIT $2
+	`
+	switch {
+	case before != nil:
+		args = append(args, before.Timestamp, before.ID)
+		query = `
+			SELECT ` + messageColumns + `
+			FROM messages
+			WHERE room_id = $1 AND parent_id IS NULL AND (timestamp, id) < ($3, $4)
+			ORDER BY timestamp DESC, id DESC
+			LIMIT $2
+		`
+	case after != nil:
+		args = append(args, after.Timestamp, after.ID)
+		query = `
+			SELECT ` + messageColumns + `
+			FROM messages
+			WHERE room_id = $1 AND parent_id IS NULL AND (timestamp, id) > ($3, $4)
+			ORDER BY timestamp ASC, id ASC
+			LIMIT $2
+		`
+	}
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching messages for room %s: %w", roomID, err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var msg domain.Message
-		if err := rows.Scan(&msg.ID, &msg.UserID, &msg.RoomID, &msg.Message, &msg.Timestamp); err != nil {
+		msg, err := scanMessage(rows)
+		if err != nil {
 			return nil, fmt.Errorf("error scanning message for room %s: %w", roomID, err)
 		}
-		messages = append(messages, msg)
+		messages = append(messages, *msg)
 	}
 
-	// Check for any errors that occurred during iteration
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("row iteration error: %w", err)
 	}
 
+	// Pages after a cursor are read oldest first, flip them to newest first
+	if after != nil {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
 	return messages, nil
 }
+
+// GetMessagesSinceSeq fetches up to 'limit' messages of a room, replies
+// included, whose sequence number is greater than since, in sequence order
+func (r *MessageRepository) GetMessagesSinceSeq(roomID string, since int64, limit int) ([]domain.Message, error) {
+	messages := []domain.Message{}
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE room_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3
+	`
+	rows, err := r.db.Query(query, roomID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching messages since %d for room %s: %w", since, roomID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning message for room %s: %w", roomID, err)
+		}
+		messages = append(messages, *msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return messages, nil
+}
+
+// GetLastMessages returns the latest top-level message of each of the given
+// rooms, keyed by room ID. Rooms without messages are left out.
+func (r *MessageRepository) GetLastMessages(roomIDs []string) (map[string]domain.Message, error) {
+	last := make(map[string]domain.Message, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return last, nil
+	}
+
+	query := `
+		SELECT DISTINCT ON (room_id) ` + messageColumns + `
+		FROM messages
+		WHERE room_id = ANY($1::int[]) AND parent_id IS NULL
+		ORDER BY room_id, timestamp DESC, id DESC
+	`
+	rows, err := r.db.Query(query, pq.Array(roomIDs))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching last messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning last message: %w", err)
+		}
+		last[msg.RoomID] = *msg
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return last, nil
+}
@@ -0,0 +1,183 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// banColumns and muteColumns are the column lists scanned by scanBan and scanMute
+const (
+	banColumns  = `room_id, user_id, COALESCE(banned_by, 0), reason, created_at, expires_at`
+	muteColumns = `room_id, user_id, COALESCE(muted_by, 0), reason, created_at, expires_at`
+)
+
+type ModerationRepository struct {
+	db *sql.DB
+}
+
+func NewModerationRepository(db *sql.DB) *ModerationRepository {
+	return &ModerationRepository{db: db}
+}
+
+// scanBan reads a row selected with banColumns
+func scanBan(row rowScanner) (*domain.RoomBan, error) {
+	var ban domain.RoomBan
+	var expiresAt sql.NullTime
+	if err := row.Scan(&ban.RoomID, &ban.UserID, &ban.BannedBy, &ban.Reason, &ban.CreatedAt, &expiresAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	}
+	return &ban, nil
+}
+
+// scanMute reads a row selected with muteColumns
+func scanMute(row rowScanner) (*domain.RoomMute, error) {
+	var mute domain.RoomMute
+	if err := row.Scan(&mute.RoomID, &mute.UserID, &mute.MutedBy, &mute.Reason, &mute.CreatedAt, &mute.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &mute, nil
+}
+
+// BanUser bans a user from a room and removes their membership. Banning a
+// banned user replaces the previous ban.
+func (r *ModerationRepository) BanUser(ban *domain.RoomBan) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction for ban: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO room_bans (room_id, user_id, banned_by, reason, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET banned_by = EXCLUDED.banned_by, reason = EXCLUDED.reason,
+		    created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+		RETURNING created_at
+	`
+	if err := tx.QueryRow(query, ban.RoomID, ban.UserID, ban.BannedBy, ban.Reason, ban.ExpiresAt).Scan(&ban.CreatedAt); err != nil {
+		return fmt.Errorf("error banning user %d from room %s: %w", ban.UserID, ban.RoomID, err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM room_members WHERE room_id = $1 AND user_id = $2`, ban.RoomID, ban.UserID); err != nil {
+		return fmt.Errorf("error removing user %d from room %s: %w", ban.UserID, ban.RoomID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing ban of user %d: %w", ban.UserID, err)
+	}
+	return nil
+}
+
+// UnbanUser lifts the ban of a user from a room
+func (r *ModerationRepository) UnbanUser(roomID string, userID int) error {
+	res, err := r.db.Exec(`DELETE FROM room_bans WHERE room_id = $1 AND user_id = $2`, roomID, userID)
+	if err != nil {
+		return fmt.Errorf("error unbanning user %d from room %s: %w", userID, roomID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("ban of user %d in room %s: %w", userID, roomID, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetActiveBan returns the ban of a user from a room in effect at the given
+// time, or nil if the user is not banned
+func (r *ModerationRepository) GetActiveBan(roomID string, userID int, now time.Time) (*domain.RoomBan, error) {
+	query := `
+		SELECT ` + banColumns + `
+		FROM room_bans
+		WHERE room_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > $3)
+	`
+	ban, err := scanBan(r.db.QueryRow(query, roomID, userID, now))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching ban of user %d in room %s: %w", userID, roomID, err)
+	}
+	return ban, nil
+}
+
+// GetActiveBans lists the bans of a room in effect at the given time
+func (r *ModerationRepository) GetActiveBans(roomID string, now time.Time) ([]domain.RoomBan, error) {
+	bans := []domain.RoomBan{}
+	query := `
+		SELECT ` + banColumns + `
+		FROM room_bans
+		WHERE room_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		ORDER BY created_at DESC
+	`
+	rows, err := r.db.Query(query, roomID, now)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bans of room %s: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ban, err := scanBan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning ban of room %s: %w", roomID, err)
+		}
+		bans = append(bans, *ban)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return bans, nil
+}
+
+// MuteUser mutes a user in a room. Muting a muted user replaces the
+// previous mute.
+func (r *ModerationRepository) MuteUser(mute *domain.RoomMute) error {
+	query := `
+		INSERT INTO room_mutes (room_id, user_id, muted_by, reason, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET muted_by = EXCLUDED.muted_by, reason = EXCLUDED.reason,
+		    created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+		RETURNING created_at
+	`
+	if err := r.db.QueryRow(query, mute.RoomID, mute.UserID, mute.MutedBy, mute.Reason, mute.ExpiresAt).Scan(&mute.CreatedAt); err != nil {
+		return fmt.Errorf("error muting user %d in room %s: %w", mute.UserID, mute.RoomID, err)
+	}
+	return nil
+}
+
+// UnmuteUser lifts the mute of a user in a room
+func (r *ModerationRepository) UnmuteUser(roomID string, userID int) error {
+	res, err := r.db.Exec(`DELETE FROM room_mutes WHERE room_id = $1 AND user_id = $2`, roomID, userID)
+	if err != nil {
+		return fmt.Errorf("error unmuting user %d in room %s: %w", userID, roomID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("mute of user %d in room %s: %w", userID, roomID, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// GetActiveMute returns the mute of a user in a room in effect at the given
+// time, or nil if the user is not muted
+func (r *ModerationRepository) GetActiveMute(roomID string, userID int, now time.Time) (*domain.RoomMute, error) {
+	query := `
+		SELECT ` + muteColumns + `
+		FROM room_mutes
+		WHERE room_id = $1 AND user_id = $2 AND expires_at > $3
+	`
+	mute, err := scanMute(r.db.QueryRow(query, roomID, userID, now))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching mute of user %d in room %s: %w", userID, roomID, err)
+	}
+	return mute, nil
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+type ReactionRepository struct {
+	db *sql.DB
+}
+
+func NewReactionRepository(db *sql.DB) *ReactionRepository {
+	return &ReactionRepository{db: db}
+}
+
+// AddReaction records that a user reacted to a message with an emoji. It
+// reports whether the reaction is new and how many users reacted with it.
+func (r *ReactionRepository) AddReaction(messageID, userID int, emoji string) (bool, int, error) {
+	query := `
+		INSERT INTO message_reactions (message_id, user_id, emoji)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+	res, err := r.db.Exec(query, messageID, userID, emoji)
+	if err != nil {
+		return false, 0, fmt.Errorf("error adding reaction to message %d: %w", messageID, err)
+	}
+
+	added, _ := res.RowsAffected()
+	count, err := r.countReactions(messageID, emoji)
+	return added > 0, count, err
+}
+
+// RemoveReaction deletes a user's reaction. It reports whether a reaction was
+// removed and how many users still react with the emoji.
+func (r *ReactionRepository) RemoveReaction(messageID, userID int, emoji string) (bool, int, error) {
+	query := `
+		DELETE FROM message_reactions
+		WHERE message_id = $1 AND user_id = $2 AND emoji = $3
+	`
+	res, err := r.db.Exec(query, messageID, userID, emoji)
+	if err != nil {
+		return false, 0, fmt.Errorf("error removing reaction from message %d: %w", messageID, err)
+	}
+
+	removed, _ := res.RowsAffected()
+	count, err := r.countReactions(messageID, emoji)
+	return removed > 0, count, err
+}
+
+func (r *ReactionRepository) countReactions(messageID int, emoji string) (int, error) {
+	var count int
+	query := `SELECT COUNT(1) FROM message_reactions WHERE message_id = $1 AND emoji = $2`
+	if err := r.db.QueryRow(query, messageID, emoji).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting reactions on message %d: %w", messageID, err)
+	}
+	return count, nil
+}
+
+// AttachReactions fills in the aggregated reactions of each message, flagging
+// the ones left by viewerID
+func (r *ReactionRepository) AttachReactions(messages []domain.Message, viewerID int) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(messages))
+	index := make(map[int]int, len(messages))
+	for i, msg := range messages {
+		ids[i] = int64(msg.ID)
+		index[msg.ID] = i
+	}
+
+	query := `
+		SELECT message_id, emoji, COUNT(1), BOOL_OR(user_id = $2)
+		FROM message_reactions
+		WHERE message_id = ANY($1)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(created_at)
+	`
+	rows, err := r.db.Query(query, pq.Array(ids), viewerID)
+	if err != nil {
+		return fmt.Errorf("error fetching reactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var messageID int
+		var reaction domain.Reaction
+		if err := rows.Scan(&messageID, &reaction.Emoji, &reaction.Count, &reaction.Reacted); err != nil {
+			return fmt.Errorf("error scanning reaction: %w", err)
+		}
+		i := index[messageID]
+		messages[i].Reactions = append(messages[i].Reactions, reaction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return nil
+}
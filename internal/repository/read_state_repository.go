@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/lib/pq"
+)
+
+// readStateColumns is the column list scanned by scanReadState
+const readStateColumns = `room_id, user_id, last_read_seq, updated_at`
+
+type ReadStateRepository struct {
+	db *sql.DB
+}
+
+func NewReadStateRepository(db *sql.DB) *ReadStateRepository {
+	return &ReadStateRepository{db: db}
+}
+
+// scanReadState reads a row selected with readStateColumns
+func scanReadState(row rowScanner) (*domain.ReadState, error) {
+	var state domain.ReadState
+	if err := row.Scan(&state.RoomID, &state.UserID, &state.LastReadSeq, &state.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// MarkRead moves the read position of a user in a room forward to seq,
+// capped at the room's latest message. The position never moves backwards:
+// advanced is false, and the current state is returned, when the user had
+// already read that far.
+func (r *ReadStateRepository) MarkRead(roomID string, userID int, seq int64) (state *domain.ReadState, advanced bool, err error) {
+	query := `
+		INSERT INTO room_read_state (room_id, user_id, last_read_seq, updated_at)
+		VALUES ($1, $2, LEAST($3, (SELECT last_seq FROM rooms WHERE id = $1)), CURRENT_TIMESTAMP)
+		ON CONFLICT (room_id, user_id) DO UPDATE
+		SET last_read_seq = EXCLUDED.last_read_seq, updated_at = EXCLUDED.updated_at
+		WHERE room_read_state.last_read_seq < EXCLUDED.last_read_seq
+		RETURNING ` + readStateColumns
+
+	state, err = scanReadState(r.db.QueryRow(query, roomID, userID, seq))
+	if err == sql.ErrNoRows {
+		state, err = r.GetReadState(roomID, userID)
+		return state, false, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error marking room %s read for user %d: %w", roomID, userID, err)
+	}
+	return state, true, nil
+}
+
+// GetReadState returns the read position of a user in a room. Users who never
+// read the room are at sequence number 0.
+func (r *ReadStateRepository) GetReadState(roomID string, userID int) (*domain.ReadState, error) {
+	query := `SELECT ` + readStateColumns + ` FROM room_read_state WHERE room_id = $1 AND user_id = $2`
+	state, err := scanReadState(r.db.QueryRow(query, roomID, userID))
+	if err == sql.ErrNoRows {
+		return &domain.ReadState{RoomID: roomID, UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching read state of user %d in room %s: %w", userID, roomID, err)
+	}
+	return state, nil
+}
+
+// GetReadStates lists the read positions of the members of a room, furthest
+// first
+func (r *ReadStateRepository) GetReadStates(roomID string) ([]domain.ReadState, error) {
+	states := []domain.ReadState{}
+	query := `
+		SELECT s.room_id, s.user_id, s.last_read_seq, s.updated_at
+		FROM room_read_state s
+		JOIN room_members m ON m.room_id = s.room_id AND m.user_id = s.user_id
+		WHERE s.room_id = $1
+		ORDER BY s.last_read_seq DESC, s.user_id
+	`
+
+	rows, err := r.db.Query(query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching read states of room %s: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		state, err := scanReadState(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning read state: %w", err)
+		}
+		states = append(states, *state)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return states, nil
+}
+
+// GetUnreadCounts counts, for each of the given rooms the user is a member
+// of, the messages posted by others since the user last read the room and
+// those among them that mention the user. Rooms without unread messages are
+// left out.
+func (r *ReadStateRepository) GetUnreadCounts(userID int, roomIDs []string) (map[string]domain.UnreadCount, error) {
+	counts := make(map[string]domain.UnreadCount, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return counts, nil
+	}
+
+	query := `
+		SELECT msg.room_id,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE EXISTS (
+		           SELECT 1 FROM message_mentions mm
+		           WHERE mm.message_id = msg.id AND (mm.user_id = $1 OR mm.kind IN ('room', 'here'))
+		       ))
+		FROM messages msg
+		JOIN room_members m ON m.room_id = msg.room_id AND m.user_id = $1
+		LEFT JOIN room_read_state s ON s.room_id = msg.room_id AND s.user_id = $1
+		WHERE msg.room_id = ANY($2::int[])
+		  AND msg.seq > COALESCE(s.last_read_seq, 0)
+		  AND msg.user_id <> $1
+		  AND msg.deleted_at IS NULL
+		GROUP BY msg.room_id
+	`
+	rows, err := r.db.Query(query, userID, pq.Array(roomIDs))
+	if err != nil {
+		return nil, fmt.Errorf("error counting unread messages of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var roomID string
+		var count domain.UnreadCount
+		if err := rows.Scan(&roomID, &count.Unread, &count.Mentions); err != nil {
+			return nil, fmt.Errorf("error scanning unread count: %w", err)
+		}
+		counts[roomID] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return counts, nil
+}
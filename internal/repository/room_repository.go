@@ -7,6 +7,9 @@ import (
 	"github.com/joshbarros/golang-chat-api/internal/domain"
 )
 
+// roomColumns is the column list scanned by scanRoom
+const roomColumns = `id, room_name, visibility, direct, slow_mode, created_by, created_at`
+
 type RoomRepository struct {
 	db *sql.DB
 }
@@ -15,51 +18,79 @@ func NewRoomRepository(db *sql.DB) *RoomRepository {
 	return &RoomRepository{db: db}
 }
 
+// scanRoom reads a row selected with roomColumns
+func scanRoom(row rowScanner) (*domain.Room, error) {
+	var room domain.Room
+	if err := row.Scan(&room.ID, &room.RoomName, &room.Visibility, &room.Direct, &room.SlowMode, &room.CreatedBy, &room.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
+
 // CreateRoom inserts a new room into the database and returns the generated ID.
+// The creator of the room, if any, becomes its owner.
 func (r *RoomRepository) CreateRoom(room *domain.Room) error {
+	if room.Visibility == "" {
+		room.Visibility = domain.RoomPublic
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction for room %s: %w", room.RoomName, err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO rooms (room_name)
-		VALUES ($1)
+		INSERT INTO rooms (room_name, visibility, created_by)
+		VALUES ($1, $2, $3)
 		RETURNING id, created_at
 	`
-	err := r.db.QueryRow(query, room.RoomName).Scan(&room.ID, &room.CreatedAt)
+	err = tx.QueryRow(query, room.RoomName, room.Visibility, room.CreatedBy).Scan(&room.ID, &room.CreatedAt)
 	if err != nil {
 		return fmt.Errorf("error creating room %s: %w", room.RoomName, err)
 	}
 
+	if room.CreatedBy != nil {
+		addOwner := `INSERT INTO room_members (room_id, user_id, role) VALUES ($1, $2, $3)`
+		if _, err := tx.Exec(addOwner, room.ID, *room.CreatedBy, domain.RoleOwner); err != nil {
+			return fmt.Errorf("error adding owner to room %s: %w", room.RoomName, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing room %s: %w", room.RoomName, err)
+	}
 	return nil
 }
 
 // GetRoomByID retrieves a room by its ID
 func (r *RoomRepository) GetRoomByID(roomID string) (*domain.Room, error) {
-	var room domain.Room
 	query := `
-		SELECT id, room_name, created_at
+		SELECT ` + roomColumns + `
 		FROM rooms
 		WHERE id = $1
 	`
 
-	err := r.db.QueryRow(query, roomID).Scan(&room.ID, &room.RoomName, &room.CreatedAt)
+	room, err := scanRoom(r.db.QueryRow(query, roomID))
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("room not found with id: %s", roomID)
+			return nil, fmt.Errorf("room not found with id %s: %w", roomID, domain.ErrNotFound)
 		}
 		return nil, fmt.Errorf("error retrieving room by id %s: %w", roomID, err)
 	}
 
-	return &room, nil
+	return room, nil
 }
 
 // GetRoomByName retrieves a room by its name
 func (r *RoomRepository) GetRoomByName(roomName string) (*domain.Room, error) {
-	var room domain.Room
 	query := `
-		SELECT id, room_name, created_at
+		SELECT ` + roomColumns + `
 		FROM rooms
-		WHERE room_name = $1
+		WHERE room_name = $1 AND NOT direct
 	`
 
-	err := r.db.QueryRow(query, roomName).Scan(&room.ID, &room.RoomName, &room.CreatedAt)
+	room, err := scanRoom(r.db.QueryRow(query, roomName))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil // Return nil if no room is found
@@ -67,30 +98,35 @@ func (r *RoomRepository) GetRoomByName(roomName string) (*domain.Room, error) {
 		return nil, fmt.Errorf("error retrieving room by name %s: %w", roomName, err)
 	}
 
-	return &room, nil
+	return room, nil
 }
 
 
-// GetRooms retrieves all available rooms
-func (r *RoomRepository) GetRooms() ([]domain.Room, error) {
+// GetRooms retrieves the rooms available to a user: every public room and
+// the private rooms the user is a member of. Direct rooms are not included.
+func (r *RoomRepository) GetRooms(userID int) ([]domain.Room, error) {
 	var rooms []domain.Room
 	query := `
-		SELECT id, room_name, created_at
+		SELECT ` + roomColumns + `
 		FROM rooms
+		WHERE NOT direct
+		  AND (visibility = 'public'
+		   OR EXISTS (SELECT 1 FROM room_members m WHERE m.room_id = rooms.id AND m.user_id = $1))
+		ORDER BY id
 	`
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Query(query, userID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching rooms: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var room domain.Room
-		if err := rows.Scan(&room.ID, &room.RoomName, &room.CreatedAt); err != nil {
+		room, err := scanRoom(rows)
+		if err != nil {
 			return nil, fmt.Errorf("error scanning room: %w", err)
 		}
-		rooms = append(rooms, room)
+		rooms = append(rooms, *room)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -99,3 +135,139 @@ func (r *RoomRepository) GetRooms() ([]domain.Room, error) {
 
 	return rooms, nil
 }
+
+// SetSlowMode sets how many seconds members of a room must wait between messages
+func (r *RoomRepository) SetSlowMode(roomID string, seconds int) error {
+	res, err := r.db.Exec(`UPDATE rooms SET slow_mode = $2 WHERE id = $1`, roomID, seconds)
+	if err != nil {
+		return fmt.Errorf("error setting slow mode of room %s: %w", roomID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("room %s: %w", roomID, domain.ErrNotFound)
+	}
+	return nil
+}
+
+// directPair orders two user IDs the way direct_rooms stores them
+func directPair(userID, otherID int) (int, int) {
+	if userID < otherID {
+		return userID, otherID
+	}
+	return otherID, userID
+}
+
+// GetDirectRoom returns the direct room shared by two users, or nil if they
+// have none
+func (r *RoomRepository) GetDirectRoom(userID, otherID int) (*domain.Room, error) {
+	low, high := directPair(userID, otherID)
+	query := `
+		SELECT ` + roomColumns + `
+		FROM rooms
+		WHERE id = (SELECT room_id FROM direct_rooms WHERE user_low = $1 AND user_high = $2)
+	`
+
+	room, err := scanRoom(r.db.QueryRow(query, low, high))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error retrieving direct room of users %d and %d: %w", low, high, err)
+	}
+	return room, nil
+}
+
+// CreateDirectRoom creates a private room with both users as members. If
+// the users already share a direct room, for instance because it was created
+// concurrently, that room is returned instead.
+func (r *RoomRepository) CreateDirectRoom(userID, otherID int) (*domain.Room, error) {
+	low, high := directPair(userID, otherID)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction for direct room: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, otherID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("error checking user %d: %w", otherID, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("user %d: %w", otherID, domain.ErrNotFound)
+	}
+
+	room := &domain.Room{
+		RoomName:   fmt.Sprintf("dm:%d:%d", low, high),
+		Visibility: domain.RoomPrivate,
+		Direct:     true,
+		CreatedBy:  &userID,
+	}
+	query := `
+		INSERT INTO rooms (room_name, visibility, direct, created_by)
+		VALUES ($1, $2, TRUE, $3)
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRow(query, room.RoomName, room.Visibility, userID).Scan(&room.ID, &room.CreatedAt); err != nil {
+		return nil, fmt.Errorf("error creating direct room: %w", err)
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO direct_rooms (room_id, user_low, user_high)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_low, user_high) DO NOTHING
+	`, room.ID, low, high)
+	if err != nil {
+		return nil, fmt.Errorf("error registering direct room: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Lost the race against a concurrent request; discard our room
+		tx.Rollback()
+		return r.GetDirectRoom(low, high)
+	}
+
+	addMembers := `INSERT INTO room_members (room_id, user_id, role) VALUES ($1, $2, $4), ($1, $3, $4)`
+	if _, err := tx.Exec(addMembers, room.ID, low, high, domain.RoleMember); err != nil {
+		return nil, fmt.Errorf("error adding members to direct room: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing direct room: %w", err)
+	}
+	return room, nil
+}
+
+// GetDirectRooms lists the direct rooms a user is a member of together with
+// the other participant of each
+func (r *RoomRepository) GetDirectRooms(userID int) ([]domain.DirectConversation, error) {
+	conversations := []domain.DirectConversation{}
+	query := `
+		SELECT r.id, r.room_name, r.visibility, r.direct, r.slow_mode, r.created_by, r.created_at, u.id, u.username
+		FROM direct_rooms d
+		JOIN rooms r ON r.id = d.room_id
+		JOIN room_members m ON m.room_id = d.room_id AND m.user_id = $1
+		JOIN users u ON u.id = CASE WHEN d.user_low = $1 THEN d.user_high ELSE d.user_low END
+		WHERE d.user_low = $1 OR d.user_high = $1
+		ORDER BY r.id
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching direct rooms of user %d: %w", userID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dm domain.DirectConversation
+		if err := rows.Scan(&dm.Room.ID, &dm.Room.RoomName, &dm.Room.Visibility, &dm.Room.Direct, &dm.Room.SlowMode, &dm.Room.CreatedBy,
+			&dm.Room.CreatedAt, &dm.Participant.UserID, &dm.Participant.Username); err != nil {
+			return nil, fmt.Errorf("error scanning direct room: %w", err)
+		}
+		conversations = append(conversations, dm)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return conversations, nil
+}
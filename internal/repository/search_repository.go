@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// searchConfig is the text search configuration of messages.search_vector
+const searchConfig = "english"
+
+// headlineOptions make ts_headline return a few short fragments with the
+// matches wrapped in <mark> tags
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5"
+
+type SearchRepository struct {
+	db *sql.DB
+}
+
+func NewSearchRepository(db *sql.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+// SearchMessages returns the live messages matching a search that userID may
+// read: messages of public rooms and of the rooms userID is a member of,
+// except rooms userID is banned from. Hits are ranked best first. The query
+// text uses web search syntax: quoted phrases, "or" and -excluded words.
+func (r *SearchRepository) SearchMessages(userID int, q domain.SearchQuery, now time.Time) ([]domain.SearchHit, error) {
+	hits := []domain.SearchHit{}
+
+	// The message is escaped before highlighting so that the snippet is safe
+	// to render as HTML
+	query := `
+		SELECT ` + messageColumns + `,
+		       ts_headline('` + searchConfig + `',
+		                   replace(replace(replace(message, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+		                   query, '` + headlineOptions + `'),
+		       ts_rank(search_vector, query)
+		FROM messages, websearch_to_tsquery('` + searchConfig + `', $1) query
+		WHERE search_vector @@ query
+		  AND deleted_at IS NULL
+		  AND room_id IN (
+		      SELECT r.id FROM rooms r
+		      WHERE (r.visibility = 'public'
+		         OR EXISTS (SELECT 1 FROM room_members m WHERE m.room_id = r.id AND m.user_id = $2))
+		        AND NOT EXISTS (
+		            SELECT 1 FROM room_bans b
+		            WHERE b.room_id = r.id AND b.user_id = $2 AND (b.expires_at IS NULL OR b.expires_at > $3))
+		  )
+		  AND ($4 = '' OR room_id = NULLIF($4, '')::int)
+		  AND ($5 = 0 OR user_id = $5)
+		  AND ($6::timestamp IS NULL OR timestamp >= $6)
+		  AND ($7::timestamp IS NULL OR timestamp < $7)
+		ORDER BY ts_rank(search_vector, query) DESC, id DESC
+		LIMIT $8 OFFSET $9
+	`
+	rows, err := r.db.Query(query, q.Text, userID, now, q.RoomID, q.UserID, q.From, q.To, q.Limit, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("error searching messages: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hit domain.SearchHit
+		msg, err := scanMessage(rows, &hit.Snippet, &hit.Rank)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning search hit: %w", err)
+		}
+		hit.Message = *msg
+		hits = append(hits, hit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return hits, nil
+}
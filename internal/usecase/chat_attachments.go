@@ -0,0 +1,335 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/workerpool"
+	"github.com/joshbarros/golang-chat-api/pkg/imaging"
+	"github.com/joshbarros/golang-chat-api/pkg/security"
+	"github.com/joshbarros/golang-chat-api/pkg/storage"
+)
+
+// AttachmentConfig controls where uploads are stored, which ones are accepted
+// and how long their download links stay valid. Images are processed in the
+// background by the Thumbnails pool; when it is nil they get no thumbnail.
+type AttachmentConfig struct {
+	Store         storage.BlobStore
+	Signer        *security.URLSigner
+	MaxSize       int64         // Largest upload in bytes
+	AllowedTypes  []string      // Media types accepted, as sniffed from the data
+	URLTTL        time.Duration // How long signed download links stay valid
+	Thumbnails    *workerpool.TaskPool
+	ThumbnailSize int // Largest width and height of thumbnails in pixels
+}
+
+// Limits of attachments
+const (
+	maxAttachmentsPerMessage = 10
+	maxFilenameLength        = 255
+	attachmentKeyBytes       = 18
+	sniffLength              = 512 // Bytes http.DetectContentType looks at
+)
+
+// MaxAttachmentSize returns the largest upload accepted, in bytes
+func (uc *ChatUsecase) MaxAttachmentSize() int64 {
+	return uc.attachments.MaxSize
+}
+
+// UploadAttachment stores a file uploaded to a room by userID. The content type
+// is sniffed from the data rather than trusted from the client, and the
+// metadata of JPEG images is stripped before they are stored. The upload
+// belongs to no message until it is sent with one. Images are queued for
+// thumbnail generation.
+func (uc *ChatUsecase) UploadAttachment(roomID string, userID int, filename string, r io.Reader, size int64) (*domain.Attachment, error) {
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+	if err := uc.checkNotMuted(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	if size <= 0 {
+		return nil, fmt.Errorf("upload is empty: %w", domain.ErrInvalidInput)
+	}
+	if size > uc.attachments.MaxSize {
+		return nil, fmt.Errorf("upload of %d bytes exceeds %d: %w", size, uc.attachments.MaxSize, domain.ErrTooLarge)
+	}
+
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error reading upload: %w", err)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !uc.allowedType(contentType) {
+		return nil, fmt.Errorf("uploads of type %s are not allowed: %w", contentType, domain.ErrUnsupportedType)
+	}
+
+	token, err := security.RandomToken(attachmentKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &domain.Attachment{
+		RoomID:      roomID,
+		UserID:      userID,
+		Filename:    cleanFilename(filename),
+		ContentType: contentType,
+		Size:        size,
+		StorageKey:  "rooms/" + roomID + "/" + token,
+	}
+
+	body := io.MultiReader(bytes.NewReader(head), r)
+	if contentType == "image/jpeg" {
+		if body, attachment.Size, err = stripJPEG(body); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	if err := uc.attachments.Store.Put(ctx, attachment.StorageKey, body, attachment.Size, contentType); err != nil {
+		return nil, err
+	}
+	if err := uc.attachmentRepo.CreateAttachment(attachment); err != nil {
+		if err := uc.attachments.Store.Delete(ctx, attachment.StorageKey); err != nil {
+			log.Printf("Error deleting orphaned blob %s: %v", attachment.StorageKey, err)
+		}
+		return nil, err
+	}
+
+	uc.queueThumbnail(*attachment)
+	uc.signAttachment(attachment)
+	return attachment, nil
+}
+
+// stripJPEG reads a JPEG image and removes its metadata, which may tell where
+// and with what device the picture was taken
+func stripJPEG(r io.Reader) (io.Reader, int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading upload: %w", err)
+	}
+	stripped, err := imaging.StripJPEGMetadata(data)
+	if err != nil {
+		return nil, 0, fmt.Errorf("upload is not a valid JPEG image: %w", domain.ErrInvalidInput)
+	}
+	return bytes.NewReader(stripped), int64(len(stripped)), nil
+}
+
+// allowedType reports whether a sniffed content type is on the allow-list
+func (uc *ChatUsecase) allowedType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range uc.attachments.AllowedTypes {
+		if strings.EqualFold(mediaType, strings.TrimSpace(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFilename keeps the base name of an uploaded file, shortened to fit
+// the filename column
+func cleanFilename(filename string) string {
+	name := path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	name = strings.TrimSpace(strings.ToValidUTF8(name, ""))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	for utf8.RuneCountInString(name) > maxFilenameLength {
+		_, last := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-last]
+	}
+	return name
+}
+
+// attachmentPath is the path of the download endpoint of an attachment
+func attachmentPath(attachmentID int) string {
+	return fmt.Sprintf("/attachments/%d/download", attachmentID)
+}
+
+// thumbnailPath is the path of the download endpoint of an attachment's thumbnail
+func thumbnailPath(attachmentID int) string {
+	return fmt.Sprintf("/attachments/%d/thumbnail", attachmentID)
+}
+
+// signAttachment sets the signed download links of an attachment and of its
+// thumbnail
+func (uc *ChatUsecase) signAttachment(a *domain.Attachment) {
+	expires := time.Now().Add(uc.attachments.URLTTL).Truncate(time.Second)
+	a.URL = uc.signPath(attachmentPath(a.ID), expires)
+	a.URLExpiresAt = &expires
+	if a.Thumbnail != nil {
+		a.Thumbnail.URL = uc.signPath(thumbnailPath(a.ID), expires)
+	}
+}
+
+// signPath returns a link to p signed until expires
+func (uc *ChatUsecase) signPath(p string, expires time.Time) string {
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", uc.attachments.Signer.Sign(p, expires))
+	return p + "?" + query.Encode()
+}
+
+// attachFiles fills in the attachments of messages, with signed links
+func (uc *ChatUsecase) attachFiles(messages []domain.Message) error {
+	if err := uc.attachmentRepo.AttachAttachments(messages); err != nil {
+		return err
+	}
+	for i := range messages {
+		for j := range messages[i].Attachments {
+			uc.signAttachment(&messages[i].Attachments[j])
+		}
+	}
+	return nil
+}
+
+// checkAttachments loads the attachments requested for a new message. Only
+// uploads of the sender to the room that were not sent yet may be attached.
+func (uc *ChatUsecase) checkAttachments(roomID string, userID int, requested []domain.Attachment) ([]domain.Attachment, error) {
+	if len(requested) == 0 {
+		return nil, nil
+	}
+	if len(requested) > maxAttachmentsPerMessage {
+		return nil, fmt.Errorf("more than %d attachments: %w", maxAttachmentsPerMessage, domain.ErrInvalidInput)
+	}
+
+	ids := make([]int, len(requested))
+	for i, a := range requested {
+		ids[i] = a.ID
+	}
+	found, err := uc.attachmentRepo.GetAttachments(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]domain.Attachment, len(found))
+	for _, a := range found {
+		byID[a.ID] = a
+	}
+
+	attachments := make([]domain.Attachment, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		a, ok := byID[id]
+		if !ok || a.UserID != userID || a.RoomID != roomID {
+			return nil, fmt.Errorf("attachment %d: %w", id, domain.ErrNotFound)
+		}
+		if a.MessageID != nil || seen[id] {
+			return nil, fmt.Errorf("attachment %d was already sent: %w", id, domain.ErrInvalidInput)
+		}
+		seen[id] = true
+		attachments = append(attachments, a)
+	}
+	return attachments, nil
+}
+
+// linkAttachments attaches the uploads sent with a saved message to it
+func (uc *ChatUsecase) linkAttachments(msg *domain.Message) error {
+	if len(msg.Attachments) == 0 {
+		return nil
+	}
+
+	ids := make([]int, len(msg.Attachments))
+	for i, a := range msg.Attachments {
+		ids[i] = a.ID
+	}
+	// Reload them, thumbnails may have been made since they were checked
+	attachments, err := uc.attachmentRepo.LinkAttachments(msg.ID, msg.UserID, msg.RoomID, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range attachments {
+		uc.signAttachment(&attachments[i])
+	}
+	msg.Attachments = attachments
+	return nil
+}
+
+// GetAttachment returns an attachment with a fresh download link. Uploads
+// that were not sent yet are only visible to their uploader.
+func (uc *ChatUsecase) GetAttachment(attachmentID, userID int) (*domain.Attachment, error) {
+	a, err := uc.attachmentRepo.GetAttachment(attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := uc.CheckRoomAccess(a.RoomID, userID); err != nil {
+		return nil, err
+	}
+	if a.MessageID == nil && a.UserID != userID {
+		return nil, fmt.Errorf("attachment %d: %w", attachmentID, domain.ErrNotFound)
+	}
+
+	uc.signAttachment(a)
+	return a, nil
+}
+
+// OpenAttachment checks a signed download link and opens the attachment it
+// points to. The caller must close the returned reader.
+func (uc *ChatUsecase) OpenAttachment(attachmentID int, expires int64, signature string) (*domain.Attachment, io.ReadCloser, error) {
+	a, err := uc.verifyLink(attachmentPath(attachmentID), attachmentID, expires, signature)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blob, err := uc.openBlob(attachmentID, a.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, blob, nil
+}
+
+// OpenThumbnail checks a signed thumbnail link and opens the thumbnail of the
+// attachment it points to. The caller must close the returned reader.
+func (uc *ChatUsecase) OpenThumbnail(attachmentID int, expires int64, signature string) (*domain.Thumbnail, io.ReadCloser, error) {
+	a, err := uc.verifyLink(thumbnailPath(attachmentID), attachmentID, expires, signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.Thumbnail == nil {
+		return nil, nil, fmt.Errorf("thumbnail of attachment %d: %w", attachmentID, domain.ErrNotFound)
+	}
+
+	blob, err := uc.openBlob(attachmentID, a.Thumbnail.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.Thumbnail, blob, nil
+}
+
+// verifyLink checks the signature of a link to p and loads the attachment
+// it gives access to
+func (uc *ChatUsecase) verifyLink(p string, attachmentID int, expires int64, signature string) (*domain.Attachment, error) {
+	if err := uc.attachments.Signer.Verify(p, expires, signature, time.Now()); err != nil {
+		return nil, fmt.Errorf("download link of attachment %d: %w", attachmentID, domain.ErrForbidden)
+	}
+	return uc.attachmentRepo.GetAttachment(attachmentID)
+}
+
+// openBlob opens a blob of an attachment from the store
+func (uc *ChatUsecase) openBlob(attachmentID int, key string) (io.ReadCloser, error) {
+	blob, err := uc.attachments.Store.Get(context.Background(), key)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, fmt.Errorf("blob of attachment %d: %w", attachmentID, domain.ErrNotFound)
+	}
+	return blob, err
+}
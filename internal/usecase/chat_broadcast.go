@@ -0,0 +1,284 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/gorilla/websocket"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// roomChannel returns the Redis pub/sub channel carrying a room's broadcasts
+func roomChannel(roomID string) string {
+	return "chat:room:" + roomID
+}
+
+// controlChannel returns the Redis pub/sub channel carrying commands for the
+// connections to a room
+func controlChannel(roomID string) string {
+	return "chat:room:" + roomID + ":control"
+}
+
+// userChannel is the Redis pub/sub channel carrying frames addressed to
+// individual users, whichever rooms they are connected to
+const userChannel = "chat:users"
+
+// userEvent is a frame for every local connection of a user
+type userEvent struct {
+	UserID int             `json:"user_id"`
+	Frame  json.RawMessage `json:"frame"`
+}
+
+// Actions of the commands sent on a room's control channel
+const (
+	commandDisconnect  = "disconnect"   // Close the connections of the user
+	commandRelayOthers = "relay_others" // Send the frame to everyone but the user
+)
+
+// roomCommand asks every instance to act on the local connections of a user
+// to a room
+type roomCommand struct {
+	Action string          `json:"action"`
+	UserID int             `json:"user_id"`
+	Reason string          `json:"reason,omitempty"`
+	Frame  json.RawMessage `json:"frame,omitempty"`
+}
+
+// publishCommand sends a command to every instance with clients in the room
+func (uc *ChatUsecase) publishCommand(roomID string, cmd roomCommand) {
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		log.Printf("Error encoding command for room %s: %v", roomID, err)
+		return
+	}
+
+	if err := uc.redisClient.Publish(context.Background(), controlChannel(roomID), data).Err(); err != nil {
+		log.Printf("Error publishing command to room %s: %v", roomID, err)
+	}
+}
+
+// disconnectUser closes the connections of a user to a room on every instance
+func (uc *ChatUsecase) disconnectUser(roomID string, userID int, reason string) {
+	uc.publishCommand(roomID, roomCommand{Action: commandDisconnect, UserID: userID, Reason: reason})
+}
+
+// publishToOthers sends a frame to every client connected to the room, on
+// any instance, except the connections of userID
+func (uc *ChatUsecase) publishToOthers(roomID string, userID int, frameType domain.FrameType, payload interface{}) {
+	frame, err := domain.NewFrame(frameType, "", payload)
+	if err != nil {
+		log.Printf("Error encoding %s frame for room %s: %v", frameType, roomID, err)
+		return
+	}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Error encoding %s frame for room %s: %v", frameType, roomID, err)
+		return
+	}
+	uc.publishCommand(roomID, roomCommand{Action: commandRelayOthers, UserID: userID, Frame: data})
+}
+
+// publishToUser sends a frame to every connection of a user, on any instance
+// and in any room
+func (uc *ChatUsecase) publishToUser(userID int, frameType domain.FrameType, payload interface{}) {
+	frame, err := domain.NewFrame(frameType, "", payload)
+	if err != nil {
+		log.Printf("Error encoding %s frame for user %d: %v", frameType, userID, err)
+		return
+	}
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Error encoding %s frame for user %d: %v", frameType, userID, err)
+		return
+	}
+	event, err := json.Marshal(userEvent{UserID: userID, Frame: data})
+	if err != nil {
+		log.Printf("Error encoding %s frame for user %d: %v", frameType, userID, err)
+		return
+	}
+
+	if err := uc.redisClient.Publish(context.Background(), userChannel, event).Err(); err != nil {
+		log.Printf("Error publishing %s frame to user %d: %v", frameType, userID, err)
+	}
+}
+
+// handleUserEvent delivers a frame addressed to a user to their local
+// connections
+func (uc *ChatUsecase) handleUserEvent(payload string) {
+	var event userEvent
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Printf("Error decoding user event: %v", err)
+		return
+	}
+
+	uc.roomsMutex.RLock()
+	var clients []*Client
+	for _, roomClients := range uc.clients {
+		for _, client := range roomClients {
+			if client.UserID == event.UserID {
+				clients = append(clients, client)
+			}
+		}
+	}
+	uc.roomsMutex.RUnlock()
+
+	for _, client := range clients {
+		client.Send(event.Frame)
+	}
+}
+
+// BroadcastUserEvents subscribes to the Redis channel carrying frames
+// addressed to users and delivers them to the local connections of each user,
+// until done is closed. ready is closed once the subscription is confirmed.
+func (uc *ChatUsecase) BroadcastUserEvents(done chan bool, ready chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pubsub := uc.redisClient.Subscribe(ctx, userChannel)
+	defer pubsub.Close()
+
+	// A failed subscription is forgotten before ready is closed, so that the
+	// clients waiting on it see the failure
+	_, err := pubsub.Receive(ctx)
+	if err != nil {
+		log.Printf("Error subscribing to user events: %v", err)
+		uc.forgetUserEvents(ready)
+		close(ready)
+		return
+	}
+	close(ready)
+	defer uc.forgetUserEvents(ready)
+	log.Printf("Subscribed to user events")
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			uc.handleUserEvent(msg.Payload)
+		case <-done:
+			return
+		}
+	}
+}
+
+// handleRoomCommand applies a command received on a room's control channel
+// to the clients connected locally
+func (uc *ChatUsecase) handleRoomCommand(roomID string, payload string) {
+	var cmd roomCommand
+	if err := json.Unmarshal([]byte(payload), &cmd); err != nil {
+		log.Printf("Error decoding command for room %s: %v", roomID, err)
+		return
+	}
+
+	for _, client := range uc.GetConnectedClients(roomID) {
+		switch {
+		case cmd.Action == commandDisconnect && client.UserID == cmd.UserID:
+			log.Printf("Disconnecting user %d from room %s: %s", cmd.UserID, roomID, cmd.Reason)
+			wsConnectionsClosed.WithLabelValues(closeReasonRemoved).Inc()
+			client.CloseWithReason(websocket.ClosePolicyViolation, cmd.Reason)
+		case cmd.Action == commandRelayOthers && client.UserID != cmd.UserID:
+			client.Send(cmd.Frame)
+		}
+	}
+}
+
+// publish sends a frame to every client connected to the room, on any instance
+func (uc *ChatUsecase) publish(roomID string, frameType domain.FrameType, payload interface{}) {
+	frame, err := domain.NewFrame(frameType, "", payload)
+	if err != nil {
+		log.Printf("Error encoding %s frame for room %s: %v", frameType, roomID, err)
+		return
+	}
+	uc.publishFrame(roomID, frame)
+}
+
+// publishFrame sends an already built frame to every client connected to the room
+func (uc *ChatUsecase) publishFrame(roomID string, frame domain.Frame) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Error encoding %s frame for room %s: %v", frame.Type, roomID, err)
+		return
+	}
+
+	if err := uc.redisClient.Publish(context.Background(), roomChannel(roomID), data).Err(); err != nil {
+		log.Printf("Error publishing %s frame to room %s: %v", frame.Type, roomID, err)
+	}
+}
+
+// forgetRoomSubscription drops the subscription to a room whose broadcaster
+// stopped, so that the next client subscribes again. ready identifies the
+// subscription, which may have been replaced in the meantime.
+func (uc *ChatUsecase) forgetRoomSubscription(roomID string, ready chan struct{}) {
+	uc.roomsMutex.Lock()
+	defer uc.roomsMutex.Unlock()
+	if sub, exists := uc.rooms[roomID]; exists && sub.ready == ready {
+		delete(uc.rooms, roomID)
+	}
+}
+
+// forgetUserEvents drops the subscription to user events once it stopped, so
+// that the next client subscribes again
+func (uc *ChatUsecase) forgetUserEvents(ready chan struct{}) {
+	uc.roomsMutex.Lock()
+	defer uc.roomsMutex.Unlock()
+	if uc.userEvents != nil && uc.userEvents.ready == ready {
+		uc.userEvents = nil
+	}
+}
+
+// BroadcastMessages subscribes to the room's Redis channels and relays every
+// frame published by any instance to the clients connected locally, until
+// done is closed. Commands received on the control channel are applied to
+// the local clients. ready is closed once the subscription is confirmed.
+func (uc *ChatUsecase) BroadcastMessages(roomID string, done chan bool, ready chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pubsub := uc.redisClient.Subscribe(ctx, roomChannel(roomID), controlChannel(roomID))
+	defer pubsub.Close()
+
+	// Wait for both subscriptions to be confirmed before relaying. A failed
+	// subscription is forgotten before ready is closed, so that the clients
+	// waiting on it see the failure.
+	var err error
+	for i := 0; i < 2 && err == nil; i++ {
+		_, err = pubsub.Receive(ctx)
+	}
+	if err != nil {
+		log.Printf("Error subscribing to room %s: %v", roomID, err)
+		uc.forgetRoomSubscription(roomID, ready)
+		close(ready)
+		return
+	}
+	close(ready)
+	defer uc.forgetRoomSubscription(roomID, ready)
+	log.Printf("Subscribed to broadcasts for room %s", roomID)
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			if msg.Channel == controlChannel(roomID) {
+				uc.handleRoomCommand(roomID, msg.Payload)
+				continue
+			}
+
+			// Broadcast frame to all local clients in the room
+			data := []byte(msg.Payload)
+			for _, client := range uc.GetConnectedClients(roomID) {
+				client.Send(data)
+			}
+		case <-done:
+			log.Printf("Shutting down room %s", roomID)
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// OpenDirectRoom returns the direct room shared by userID and otherID,
+// creating it on first use. Users who left the room rejoin it.
+func (uc *ChatUsecase) OpenDirectRoom(userID, otherID int) (*domain.Room, error) {
+	if userID == otherID {
+		return nil, fmt.Errorf("user %d cannot message themselves: %w", userID, domain.ErrInvalidInput)
+	}
+
+	room, err := uc.roomRepo.GetDirectRoom(userID, otherID)
+	if err != nil {
+		return nil, err
+	}
+
+	if room == nil {
+		room, err = uc.roomRepo.CreateDirectRoom(userID, otherID)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Direct room %s opened between users %d and %d", room.ID, userID, otherID)
+		return room, nil
+	}
+
+	if err := uc.memberRepo.AddMember(room.ID, userID, domain.RoleMember); err != nil {
+		return nil, err
+	}
+	return room, nil
+}
+
+// GetDirectRooms lists the direct rooms userID is still a member of with
+// their last message, most recently active first
+func (uc *ChatUsecase) GetDirectRooms(userID int) ([]domain.DirectConversation, error) {
+	conversations, err := uc.roomRepo.GetDirectRooms(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roomIDs := make([]string, len(conversations))
+	for i, dm := range conversations {
+		roomIDs[i] = dm.Room.ID
+	}
+
+	last, err := uc.messageRepo.GetLastMessages(roomIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range conversations {
+		if msg, ok := last[conversations[i].Room.ID]; ok {
+			conversations[i].LastMessage = &msg
+		}
+	}
+
+	sort.SliceStable(conversations, func(i, j int) bool {
+		return lastActivity(conversations[i]).After(lastActivity(conversations[j]))
+	})
+	return conversations, nil
+}
+
+// lastActivity is when the last message was posted to a direct room, or when
+// the room was opened if it has no messages
+func lastActivity(dm domain.DirectConversation) time.Time {
+	if dm.LastMessage != nil {
+		return dm.LastMessage.Timestamp
+	}
+	return dm.Room.CreatedAt
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/pkg/security"
+)
+
+// Invite lifetimes
+const (
+	DefaultInviteTTL = 24 * time.Hour
+	MaxInviteTTL     = 30 * 24 * time.Hour
+)
+
+// inviteTokenBytes is the entropy of invite tokens
+const inviteTokenBytes = 24
+
+// CreateInvite mints an invite to a room on behalf of userID. A zero ttl
+// uses DefaultInviteTTL and a zero maxUses allows unlimited uses.
+func (uc *ChatUsecase) CreateInvite(roomID string, userID int, ttl time.Duration, maxUses int) (*domain.Invite, error) {
+	if ttl < 0 || ttl > MaxInviteTTL || maxUses < 0 {
+		return nil, fmt.Errorf("invalid invite limits: %w", domain.ErrInvalidInput)
+	}
+	if ttl == 0 {
+		ttl = DefaultInviteTTL
+	}
+
+	room, err := uc.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if room.Direct {
+		return nil, fmt.Errorf("room %s is a direct room: %w", roomID, domain.ErrInvalidInput)
+	}
+	if err := uc.permissions.Check(roomID, userID, PermManageInvites); err != nil {
+		return nil, err
+	}
+
+	token, err := security.RandomToken(inviteTokenBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error generating invite token: %w", err)
+	}
+
+	invite := &domain.Invite{
+		Token:     token,
+		RoomID:    roomID,
+		CreatedBy: userID,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+	if maxUses > 0 {
+		invite.MaxUses = &maxUses
+	}
+
+	if err := uc.inviteRepo.CreateInvite(invite); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %d created invite %d for room %s", userID, invite.ID, roomID)
+	return invite, nil
+}
+
+// GetInvites lists the invites of a room to the users who may manage them
+func (uc *ChatUsecase) GetInvites(roomID string, userID int) ([]domain.Invite, error) {
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return nil, err
+	}
+	if err := uc.permissions.Check(roomID, userID, PermManageInvites); err != nil {
+		return nil, err
+	}
+	return uc.inviteRepo.GetInvitesByRoom(roomID)
+}
+
+// RevokeInvite stops an invite from being accepted
+func (uc *ChatUsecase) RevokeInvite(roomID string, inviteID, userID int) (*domain.Invite, error) {
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return nil, err
+	}
+	if err := uc.permissions.Check(roomID, userID, PermManageInvites); err != nil {
+		return nil, err
+	}
+
+	invite, err := uc.inviteRepo.RevokeInvite(roomID, inviteID)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %d revoked invite %d of room %s", userID, inviteID, roomID)
+	return invite, nil
+}
+
+// GetInviteRedemptions lists who joined a room through one of its invites
+func (uc *ChatUsecase) GetInviteRedemptions(roomID string, inviteID, userID int) ([]domain.InviteRedemption, error) {
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return nil, err
+	}
+	if err := uc.permissions.Check(roomID, userID, PermManageInvites); err != nil {
+		return nil, err
+	}
+	return uc.inviteRepo.GetRedemptions(roomID, inviteID)
+}
+
+// AcceptInvite makes userID a member of the room the invite is for, unless
+// they are banned from it
+func (uc *ChatUsecase) AcceptInvite(token string, userID int) (*domain.Room, error) {
+	if token == "" {
+		return nil, fmt.Errorf("invite token is empty: %w", domain.ErrInvalidInput)
+	}
+
+	invite, err := uc.inviteRepo.GetInviteByToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.checkNotBanned(invite.RoomID, userID); err != nil {
+		return nil, err
+	}
+
+	invite, err = uc.inviteRepo.RedeemInvite(token, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %d joined room %s through invite %d", userID, invite.RoomID, invite.ID)
+	return uc.roomRepo.GetRoomByID(invite.RoomID)
+}
@@ -0,0 +1,163 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/workerpool"
+	"github.com/joshbarros/golang-chat-api/pkg/unfurl"
+)
+
+// LinkPreviewConfig controls how the links of messages are previewed. Links
+// are fetched in the background by the Pool; when Fetcher is nil messages get
+// no previews.
+type LinkPreviewConfig struct {
+	Fetcher  *unfurl.Fetcher
+	Pool     *workerpool.TaskPool
+	CacheTTL time.Duration // How long the preview of a link is reused
+}
+
+// linkPreviewFailureTTL is how long a link that could not be previewed is
+// left alone
+const linkPreviewFailureTTL = 10 * time.Minute
+
+// linkPreviewSaveAttempts bounds how often saving previews is retried when
+// the message is edited without changing its links while they are saved
+const linkPreviewSaveAttempts = 3
+
+// linkPreviewKey is the Redis key caching the preview of a link
+func linkPreviewKey(link string) string {
+	sum := sha256.Sum256([]byte(link))
+	return "chat:unfurl:" + hex.EncodeToString(sum[:])
+}
+
+// queueLinkPreviews queues the previewing of the links of a message. For
+// edits, previous is the text before the edit; nothing is done when the edit
+// left the links alone.
+func (uc *ChatUsecase) queueLinkPreviews(msg domain.Message, previous string) {
+	if uc.linkPreviews.Fetcher == nil {
+		return
+	}
+	links, previousLinks := ExtractURLs(msg.Message), ExtractURLs(previous)
+	if sameURLs(links, previousLinks) {
+		return
+	}
+
+	// Previews are a nicety, they must not hold up the saving of messages
+	queued := uc.linkPreviews.Pool.TryAddTask(workerpool.Task{
+		Name: fmt.Sprintf("link previews of message %d", msg.ID),
+		Run:  func() error { return uc.makeLinkPreviews(msg, links, len(previousLinks) > 0) },
+	})
+	if !queued {
+		log.Printf("Link preview queue is full, skipping the links of message %d", msg.ID)
+	}
+}
+
+// makeLinkPreviews fetches the previews of the links of a message, saves them
+// and tells the room. Links that cannot be previewed are left out. Rooms are
+// only told about an empty list when it replaces the previews of earlier links.
+// Previews are dropped when the message was deleted or its links were edited
+// while they were fetched; the edit queued previews of its own.
+func (uc *ChatUsecase) makeLinkPreviews(msg domain.Message, links []string, replace bool) error {
+	previews := []domain.LinkPreview{}
+	for _, link := range links {
+		if preview := uc.linkPreview(link); preview != nil {
+			previews = append(previews, *preview)
+		}
+	}
+	if len(previews) == 0 && !replace {
+		return nil
+	}
+
+	saved, err := uc.saveLinkPreviews(msg.ID, links, previews)
+	if err != nil || !saved {
+		return err
+	}
+
+	uc.publish(msg.RoomID, domain.FrameLinkPreview, domain.LinkPreviewPayload{
+		MessageID: msg.ID,
+		RoomID:    msg.RoomID,
+		Previews:  previews,
+	})
+	return nil
+}
+
+// saveLinkPreviews saves the previews of a message as long as it still has
+// the links they were made for, and reports whether it did
+func (uc *ChatUsecase) saveLinkPreviews(messageID int, links []string, previews []domain.LinkPreview) (bool, error) {
+	for attempt := 0; attempt < linkPreviewSaveAttempts; attempt++ {
+		current, err := uc.messageRepo.GetMessageByID(messageID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if current.DeletedAt != nil || !sameURLs(ExtractURLs(current.Message), links) {
+			return false, nil
+		}
+
+		// The text is checked again as the message is locked for the save
+		err = uc.linkPreviewRepo.SaveLinkPreviews(messageID, current.Message, previews)
+		if !errors.Is(err, domain.ErrNotFound) {
+			return err == nil, err
+		}
+	}
+	log.Printf("Message %d keeps changing, dropping its link previews", messageID)
+	return false, nil
+}
+
+// linkPreview returns the preview of a link, from the Redis cache when it was
+// fetched recently, or nil when it cannot be previewed
+func (uc *ChatUsecase) linkPreview(link string) *domain.LinkPreview {
+	ctx := context.Background()
+	key := linkPreviewKey(link)
+
+	// Links that could not be previewed are cached as an empty string
+	cached, err := uc.redisClient.Get(ctx, key).Result()
+	switch {
+	case err == nil && cached == "":
+		return nil
+	case err == nil:
+		var preview domain.LinkPreview
+		if err := json.Unmarshal([]byte(cached), &preview); err == nil {
+			return &preview
+		}
+		log.Printf("Error decoding cached preview of %s: %v", link, err)
+	case err != redis.Nil:
+		log.Printf("Error reading cached preview of %s: %v", link, err)
+	}
+
+	p, err := uc.linkPreviews.Fetcher.Fetch(ctx, link)
+	if err != nil {
+		log.Printf("No preview of %s: %v", link, err)
+		if err := uc.redisClient.Set(ctx, key, "", linkPreviewFailureTTL).Err(); err != nil {
+			log.Printf("Error caching preview of %s: %v", link, err)
+		}
+		return nil
+	}
+
+	preview := &domain.LinkPreview{
+		URL:         p.URL,
+		Title:       p.Title,
+		Description: p.Description,
+		Image:       p.Image,
+		SiteName:    p.SiteName,
+	}
+	data, err := json.Marshal(preview)
+	if err == nil {
+		err = uc.redisClient.Set(ctx, key, data, uc.linkPreviews.CacheTTL).Err()
+	}
+	if err != nil {
+		log.Printf("Error caching preview of %s: %v", link, err)
+	}
+	return preview
+}
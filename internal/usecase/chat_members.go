@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// CheckRoomAccess fetches a room and makes sure userID may read and post in
+// it. Public rooms are open to everyone, private rooms only to their members.
+// Banned users are kept out of both.
+func (uc *ChatUsecase) CheckRoomAccess(roomID string, userID int) (*domain.Room, error) {
+	room, err := uc.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.checkNotBanned(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	if room.Visibility != domain.RoomPrivate {
+		return room, nil
+	}
+
+	member, err := uc.memberRepo.IsMember(roomID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		return nil, fmt.Errorf("user %d is not a member of room %s: %w", userID, roomID, domain.ErrForbidden)
+	}
+	return room, nil
+}
+
+// JoinRoom makes userID a member of a public room. Private rooms cannot be
+// joined directly.
+func (uc *ChatUsecase) JoinRoom(roomID string, userID int) error {
+	room, err := uc.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return err
+	}
+
+	if err := uc.checkNotBanned(roomID, userID); err != nil {
+		return err
+	}
+
+	if room.Visibility == domain.RoomPrivate {
+		member, err := uc.memberRepo.IsMember(roomID, userID)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return fmt.Errorf("room %s is private: %w", roomID, domain.ErrForbidden)
+		}
+		return nil
+	}
+
+	if err := uc.memberRepo.AddMember(roomID, userID, domain.RoleMember); err != nil {
+		return err
+	}
+
+	log.Printf("User %d joined room %s", userID, roomID)
+	return nil
+}
+
+// LeaveRoom removes userID from the members of a room. The owner has to
+// transfer ownership before leaving.
+func (uc *ChatUsecase) LeaveRoom(roomID string, userID int) error {
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return err
+	}
+
+	role, err := uc.permissions.Role(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if role == domain.RoleOwner {
+		return fmt.Errorf("owner of room %s must transfer ownership before leaving: %w", roomID, domain.ErrForbidden)
+	}
+
+	if err := uc.memberRepo.RemoveMember(roomID, userID); err != nil {
+		return err
+	}
+
+	log.Printf("User %d left room %s", userID, roomID)
+	return nil
+}
+
+// GetRoomMembers lists the members of a room and their roles
+func (uc *ChatUsecase) GetRoomMembers(roomID string, userID int) ([]domain.RoomMember, error) {
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+	return uc.memberRepo.GetMembers(roomID)
+}
+
+// SetMemberRole changes the role of targetID in a room on behalf of actorID.
+// Assigning the owner role transfers ownership, demoting the owner to admin.
+func (uc *ChatUsecase) SetMemberRole(roomID string, actorID, targetID int, role domain.Role) error {
+	if !ValidRole(role) {
+		return fmt.Errorf("unknown role %q: %w", role, domain.ErrInvalidInput)
+	}
+
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return err
+	}
+
+	actor, err := uc.permissions.Role(roomID, actorID)
+	if err != nil {
+		return err
+	}
+	target, err := uc.permissions.Role(roomID, targetID)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return fmt.Errorf("user %d is not a member of room %s: %w", targetID, roomID, domain.ErrNotFound)
+	}
+
+	if actorID == targetID || !CanAssignRole(actor, target, role) {
+		return fmt.Errorf("user %d may not make user %d %s in room %s: %w", actorID, targetID, role, roomID, domain.ErrForbidden)
+	}
+
+	if role == domain.RoleOwner {
+		err = uc.memberRepo.TransferOwnership(roomID, actorID, targetID)
+	} else {
+		err = uc.memberRepo.SetRole(roomID, targetID, role)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("User %d made user %d %s in room %s", actorID, targetID, role, roomID)
+	return nil
+}
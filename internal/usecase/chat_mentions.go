@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"log"
+	"strings"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// resolveMentions finds the mentions in the text of a message posted to a
+// room. User mentions are resolved to members of the room; mentions of anyone
+// else are left out.
+func (uc *ChatUsecase) resolveMentions(roomID, text string) ([]domain.Mention, error) {
+	parsed := ParseMentions(text)
+
+	var usernames []string
+	for _, mention := range parsed {
+		if mention.Kind == domain.MentionUser {
+			usernames = append(usernames, mention.Username)
+		}
+	}
+
+	users, err := uc.mentionRepo.ResolveUsernames(roomID, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentions []domain.Mention
+	for _, mention := range parsed {
+		if mention.Kind == domain.MentionUser {
+			user, ok := users[strings.ToLower(mention.Username)]
+			if !ok {
+				continue
+			}
+			mention.UserID = user.UserID
+			mention.Username = user.Username
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions, nil
+}
+
+// recordMentions saves the mentions in the text of a saved message, replacing
+// the previous ones, and attaches them to the message
+func (uc *ChatUsecase) recordMentions(msg *domain.Message, previous []domain.Mention) error {
+	mentions, err := uc.resolveMentions(msg.RoomID, msg.Message)
+	if err != nil {
+		return err
+	}
+
+	if len(mentions) > 0 || len(previous) > 0 {
+		if err := uc.mentionRepo.SaveMentions(msg.ID, mentions); err != nil {
+			return err
+		}
+	}
+	msg.Mentions = mentions
+	return nil
+}
+
+// mentionRecipients lists the users addressed by mentions in a room: the
+// mentioned members, every member for @room and the members who are online
+// for @here. The author of the message is never a recipient.
+func (uc *ChatUsecase) mentionRecipients(roomID string, authorID int, mentions []domain.Mention) (map[int]bool, error) {
+	recipients := make(map[int]bool)
+	var everyone, online bool
+	for _, mention := range mentions {
+		switch mention.Kind {
+		case domain.MentionUser:
+			recipients[mention.UserID] = true
+		case domain.MentionRoom:
+			everyone = true
+		case domain.MentionHere:
+			online = true
+		}
+	}
+
+	if everyone || online {
+		members, err := uc.memberRepo.GetMembers(roomID)
+		if err != nil {
+			return nil, err
+		}
+
+		userIDs := make([]int, len(members))
+		for i, member := range members {
+			userIDs[i] = member.UserID
+		}
+
+		if everyone {
+			for _, userID := range userIDs {
+				recipients[userID] = true
+			}
+		} else {
+			presences, err := uc.presence.Get(userIDs...)
+			if err != nil {
+				return nil, err
+			}
+			for _, presence := range presences {
+				if presence.Connections > 0 {
+					recipients[presence.UserID] = true
+				}
+			}
+		}
+	}
+
+	delete(recipients, authorID)
+	return recipients, nil
+}
+
+// notifyMentions sends a mention frame with the message to every user it
+// addresses, whichever room they are connected to. Users who were already
+// addressed by the previous mentions of an edited message are skipped.
+func (uc *ChatUsecase) notifyMentions(msg domain.Message, previous []domain.Mention) {
+	if len(msg.Mentions) == 0 {
+		return
+	}
+
+	recipients, err := uc.mentionRecipients(msg.RoomID, msg.UserID, msg.Mentions)
+	if err != nil {
+		log.Printf("Error finding users mentioned by message %d: %v", msg.ID, err)
+		return
+	}
+
+	if len(previous) > 0 {
+		notified, err := uc.mentionRecipients(msg.RoomID, msg.UserID, previous)
+		if err != nil {
+			log.Printf("Error finding users mentioned by message %d: %v", msg.ID, err)
+			return
+		}
+		for userID := range notified {
+			delete(recipients, userID)
+		}
+	}
+
+	for userID := range recipients {
+		uc.publishToUser(userID, domain.FrameMention, msg)
+	}
+}
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// getRoomMessage fetches a message and makes sure it belongs to the room
+func (uc *ChatUsecase) getRoomMessage(roomID string, messageID int) (*domain.Message, error) {
+	msg, err := uc.messageRepo.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.RoomID != roomID {
+		return nil, fmt.Errorf("message %d in room %s: %w", messageID, roomID, domain.ErrNotFound)
+	}
+	return msg, nil
+}
+
+// attachMessageDetails fills in the mentions, attachments and link previews
+// of messages
+func (uc *ChatUsecase) attachMessageDetails(messages []domain.Message) error {
+	if err := uc.mentionRepo.AttachMentions(messages); err != nil {
+		return err
+	}
+	if err := uc.linkPreviewRepo.AttachLinkPreviews(messages); err != nil {
+		return err
+	}
+	return uc.attachFiles(messages)
+}
+
+// getOwnMessage fetches a live message of the room that was posted by userID
+func (uc *ChatUsecase) getOwnMessage(roomID string, messageID, userID int) (*domain.Message, error) {
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	msg, err := uc.getRoomMessage(roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return nil, fmt.Errorf("message %d was deleted: %w", messageID, domain.ErrNotFound)
+	}
+	if msg.UserID != userID {
+		return nil, fmt.Errorf("user %d is not the author of message %d: %w", userID, messageID, domain.ErrForbidden)
+	}
+	return msg, nil
+}
+
+// EditMessage changes the text of a message and broadcasts the edit to the room.
+// Only the author of a message may edit it, and not while muted.
+func (uc *ChatUsecase) EditMessage(roomID string, messageID, userID int, text string) (*domain.Message, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("message is empty: %w", domain.ErrInvalidInput)
+	}
+
+	own, err := uc.getOwnMessage(roomID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.checkNotMuted(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	// Only users mentioned for the first time by the edit are notified
+	previous := []domain.Message{*own}
+	if err := uc.mentionRepo.AttachMentions(previous); err != nil {
+		return nil, err
+	}
+
+	msg, err := uc.messageRepo.EditMessage(messageID, userID, text)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.recordMentions(msg, previous[0].Mentions); err != nil {
+		return nil, err
+	}
+	edited := []domain.Message{*msg}
+	if err := uc.attachFiles(edited); err != nil {
+		return nil, err
+	}
+	if err := uc.linkPreviewRepo.AttachLinkPreviews(edited); err != nil {
+		return nil, err
+	}
+	msg = &edited[0]
+
+	uc.publish(roomID, domain.FrameEdit, msg)
+	uc.notifyMentions(*msg, previous[0].Mentions)
+	uc.queueLinkPreviews(*msg, own.Message)
+	return msg, nil
+}
+
+// DeleteMessage soft-deletes a message and broadcasts the tombstone to the room,
+// followed by the new summary of its thread when it is a reply. Messages may
+// be deleted by their author and by the room's moderators.
+func (uc *ChatUsecase) DeleteMessage(roomID string, messageID, userID int) (*domain.Message, error) {
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	target, err := uc.getRoomMessage(roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if target.DeletedAt != nil {
+		return nil, fmt.Errorf("message %d was deleted: %w", messageID, domain.ErrNotFound)
+	}
+	if err := uc.permissions.CanDeleteMessage(userID, target); err != nil {
+		return nil, err
+	}
+
+	msg, err := uc.messageRepo.DeleteMessage(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.publish(roomID, domain.FrameDelete, msg)
+	if msg.ParentID != nil {
+		uc.publishThread(roomID, *msg.ParentID)
+	}
+	return msg, nil
+}
+
+// GetMessageRevisions returns the edit history of a message to its author
+func (uc *ChatUsecase) GetMessageRevisions(roomID string, messageID, userID int) ([]domain.MessageRevision, error) {
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	msg, err := uc.getRoomMessage(roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.UserID != userID {
+		return nil, fmt.Errorf("user %d is not the author of message %d: %w", userID, messageID, domain.ErrForbidden)
+	}
+
+	return uc.messageRepo.GetMessageRevisions(messageID)
+}
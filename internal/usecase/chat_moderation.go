@@ -0,0 +1,180 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// MaxMuteDuration bounds how long a user can be muted
+const MaxMuteDuration = 30 * 24 * time.Hour
+
+// MaxBanDuration bounds how long a temporary ban can last; longer bans are
+// made permanent by giving no duration
+const MaxBanDuration = 365 * 24 * time.Hour
+
+// maxReasonLength bounds the reason given for a moderation action
+const maxReasonLength = 500
+
+// checkModeration validates a moderation request and makes sure actorID may
+// take the action against targetID in the room
+func (uc *ChatUsecase) checkModeration(roomID string, actorID, targetID int, perm Permission, reason string) error {
+	if len(reason) > maxReasonLength {
+		return fmt.Errorf("reason is too long: %w", domain.ErrInvalidInput)
+	}
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return err
+	}
+	return uc.permissions.CheckAgainst(roomID, actorID, targetID, perm)
+}
+
+// checkNotBanned returns ErrForbidden if userID is banned from the room
+func (uc *ChatUsecase) checkNotBanned(roomID string, userID int) error {
+	ban, err := uc.moderationRepo.GetActiveBan(roomID, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if ban != nil {
+		return fmt.Errorf("user %d is banned from room %s: %w", userID, roomID, domain.ErrForbidden)
+	}
+	return nil
+}
+
+// checkNotMuted returns ErrForbidden if userID is muted in the room
+func (uc *ChatUsecase) checkNotMuted(roomID string, userID int) error {
+	mute, err := uc.moderationRepo.GetActiveMute(roomID, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if mute != nil {
+		return fmt.Errorf("user %d is muted in room %s: %w", userID, roomID, domain.ErrForbidden)
+	}
+	return nil
+}
+
+// announce tells the room about a moderation action
+func (uc *ChatUsecase) announce(roomID, event string, userID int, expiresAt *time.Time, message string) {
+	uc.publish(roomID, domain.FrameSystem, domain.SystemPayload{
+		Event:     event,
+		Message:   message,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// KickUser removes targetID from a room and disconnects them on every
+// instance. Kicked users may rejoin public rooms.
+func (uc *ChatUsecase) KickUser(roomID string, actorID, targetID int, reason string) error {
+	if err := uc.checkModeration(roomID, actorID, targetID, PermKick, reason); err != nil {
+		return err
+	}
+
+	if err := uc.memberRepo.RemoveMember(roomID, targetID); err != nil {
+		return err
+	}
+
+	log.Printf("User %d kicked user %d from room %s", actorID, targetID, roomID)
+	uc.announce(roomID, domain.SystemMemberKicked, targetID, nil, fmt.Sprintf("User %d was kicked", targetID))
+	uc.disconnectUser(roomID, targetID, "Kicked from room")
+	return nil
+}
+
+// BanUser keeps targetID out of a room for the given duration, or for good
+// if it is zero, and disconnects them on every instance
+func (uc *ChatUsecase) BanUser(roomID string, actorID, targetID int, duration time.Duration, reason string) (*domain.RoomBan, error) {
+	if duration < 0 || duration > MaxBanDuration {
+		return nil, fmt.Errorf("invalid ban duration: %w", domain.ErrInvalidInput)
+	}
+	if err := uc.checkModeration(roomID, actorID, targetID, PermBan, reason); err != nil {
+		return nil, err
+	}
+
+	ban := &domain.RoomBan{RoomID: roomID, UserID: targetID, BannedBy: actorID, Reason: reason}
+	if duration > 0 {
+		expiresAt := time.Now().UTC().Add(duration)
+		ban.ExpiresAt = &expiresAt
+	}
+	if err := uc.moderationRepo.BanUser(ban); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %d banned user %d from room %s", actorID, targetID, roomID)
+	uc.announce(roomID, domain.SystemMemberBanned, targetID, ban.ExpiresAt, fmt.Sprintf("User %d was banned", targetID))
+	uc.disconnectUser(roomID, targetID, "Banned from room")
+	return ban, nil
+}
+
+// UnbanUser lifts the ban of targetID from a room. Members of private rooms
+// need a new invite to come back.
+func (uc *ChatUsecase) UnbanUser(roomID string, actorID, targetID int) error {
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return err
+	}
+	if err := uc.permissions.Check(roomID, actorID, PermBan); err != nil {
+		return err
+	}
+
+	if err := uc.moderationRepo.UnbanUser(roomID, targetID); err != nil {
+		return err
+	}
+
+	log.Printf("User %d unbanned user %d from room %s", actorID, targetID, roomID)
+	uc.announce(roomID, domain.SystemMemberUnbanned, targetID, nil, fmt.Sprintf("User %d was unbanned", targetID))
+	return nil
+}
+
+// GetBans lists the bans in effect in a room
+func (uc *ChatUsecase) GetBans(roomID string, userID int) ([]domain.RoomBan, error) {
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return nil, err
+	}
+	if err := uc.permissions.Check(roomID, userID, PermBan); err != nil {
+		return nil, err
+	}
+	return uc.moderationRepo.GetActiveBans(roomID, time.Now().UTC())
+}
+
+// MuteUser stops targetID from posting in a room for the given duration
+func (uc *ChatUsecase) MuteUser(roomID string, actorID, targetID int, duration time.Duration, reason string) (*domain.RoomMute, error) {
+	if duration <= 0 || duration > MaxMuteDuration {
+		return nil, fmt.Errorf("invalid mute duration: %w", domain.ErrInvalidInput)
+	}
+	if err := uc.checkModeration(roomID, actorID, targetID, PermMute, reason); err != nil {
+		return nil, err
+	}
+
+	mute := &domain.RoomMute{
+		RoomID:    roomID,
+		UserID:    targetID,
+		MutedBy:   actorID,
+		Reason:    reason,
+		ExpiresAt: time.Now().UTC().Add(duration),
+	}
+	if err := uc.moderationRepo.MuteUser(mute); err != nil {
+		return nil, err
+	}
+
+	log.Printf("User %d muted user %d in room %s", actorID, targetID, roomID)
+	uc.announce(roomID, domain.SystemMemberMuted, targetID, &mute.ExpiresAt, fmt.Sprintf("User %d was muted", targetID))
+	return mute, nil
+}
+
+// UnmuteUser lets targetID post in a room again
+func (uc *ChatUsecase) UnmuteUser(roomID string, actorID, targetID int) error {
+	if _, err := uc.roomRepo.GetRoomByID(roomID); err != nil {
+		return err
+	}
+	if err := uc.permissions.Check(roomID, actorID, PermMute); err != nil {
+		return err
+	}
+
+	if err := uc.moderationRepo.UnmuteUser(roomID, targetID); err != nil {
+		return err
+	}
+
+	log.Printf("User %d unmuted user %d in room %s", actorID, targetID, roomID)
+	uc.announce(roomID, domain.SystemMemberUnmuted, targetID, nil, fmt.Sprintf("User %d was unmuted", targetID))
+	return nil
+}
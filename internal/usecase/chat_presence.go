@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/pkg/security"
+)
+
+// presenceConnIDBytes is the entropy of the IDs given to connections
+const presenceConnIDBytes = 12
+
+// TrackPresence records the connection of a client and keeps it alive with
+// heartbeats until the client disconnects. The rooms of the user are told
+// when the user comes online with their first connection and goes offline
+// with their last.
+func (uc *ChatUsecase) TrackPresence(client *Client) {
+	connID, err := security.RandomToken(presenceConnIDBytes)
+	if err != nil {
+		log.Printf("Error generating connection ID for user %d: %v", client.UserID, err)
+		return
+	}
+
+	count, err := uc.presence.Connect(client.UserID, connID)
+	if err != nil {
+		log.Printf("Error tracking presence of user %d: %v", client.UserID, err)
+		return
+	}
+	if count == 1 {
+		uc.announcePresence(client.UserID)
+	}
+
+	go func() {
+		ticker := time.NewTicker(PresenceHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := uc.presence.Heartbeat(client.UserID, connID); err != nil {
+					log.Printf("Error refreshing presence of user %d: %v", client.UserID, err)
+				}
+			case <-client.Done():
+				count, err := uc.presence.Disconnect(client.UserID, connID)
+				if err != nil {
+					log.Printf("Error clearing presence of user %d: %v", client.UserID, err)
+					return
+				}
+				if count == 0 {
+					uc.announcePresence(client.UserID)
+				}
+				return
+			}
+		}
+	}()
+}
+
+// SweepLapsedPresence periodically looks for users whose connections lapsed
+// without being closed, for instance because their instance crashed, and
+// tells their rooms they went offline. It runs until the process exits.
+func (uc *ChatUsecase) SweepLapsedPresence() {
+	ticker := time.NewTicker(PresenceHeartbeat)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		lapsed, err := uc.presence.SweepLapsed()
+		if err != nil {
+			log.Printf("Error sweeping lapsed presence: %v", err)
+		}
+		for _, userID := range lapsed {
+			uc.announcePresence(userID)
+		}
+	}
+}
+
+// announcePresence publishes the current presence of a user to every room
+// the user is a member of
+func (uc *ChatUsecase) announcePresence(userID int) {
+	presences, err := uc.presence.Get(userID)
+	if err != nil {
+		log.Printf("Error fetching presence of user %d: %v", userID, err)
+		return
+	}
+
+	roomIDs, err := uc.memberRepo.GetRoomIDsByUser(userID)
+	if err != nil {
+		log.Printf("Error fetching rooms of user %d: %v", userID, err)
+		return
+	}
+
+	for _, roomID := range roomIDs {
+		uc.publish(roomID, domain.FramePresence, presences[0])
+	}
+}
+
+// SetPresenceStatus sets the status a user shows while connected
+func (uc *ChatUsecase) SetPresenceStatus(userID int, status domain.PresenceStatus) (*domain.Presence, error) {
+	switch status {
+	case domain.StatusOnline, domain.StatusAway, domain.StatusDND:
+	default:
+		return nil, fmt.Errorf("unknown status %q: %w", status, domain.ErrInvalidInput)
+	}
+
+	if err := uc.presence.SetStatus(userID, status); err != nil {
+		return nil, err
+	}
+
+	presences, err := uc.presence.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Offline users show no status, so there is nothing to announce
+	if presences[0].Connections > 0 {
+		uc.announcePresence(userID)
+	}
+	return &presences[0], nil
+}
+
+// GetUserPresence returns the presence of a user
+func (uc *ChatUsecase) GetUserPresence(userID int) (*domain.Presence, error) {
+	presences, err := uc.presence.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &presences[0], nil
+}
+
+// GetRoomPresence returns the presence of every member of a room
+func (uc *ChatUsecase) GetRoomPresence(roomID string, userID int) ([]domain.Presence, error) {
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	members, err := uc.memberRepo.GetMembers(roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]int, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	return uc.presence.Get(userIDs...)
+}
@@ -0,0 +1,74 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// maxEmojiLength bounds the size of a reaction, which may be a multi-rune
+// emoji sequence or a short :shortcode:
+const maxEmojiLength = 64
+
+// AddReaction reacts to a message of the room and broadcasts the change.
+// Muted users cannot react, but may still withdraw their reactions.
+func (uc *ChatUsecase) AddReaction(roomID string, messageID, userID int, emoji string) (*domain.ReactionPayload, error) {
+	if err := uc.checkReactionTarget(roomID, messageID, userID, emoji); err != nil {
+		return nil, err
+	}
+	if err := uc.checkNotMuted(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	added, count, err := uc.reactionRepo.AddReaction(messageID, userID, emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	reaction := &domain.ReactionPayload{MessageID: messageID, Emoji: emoji, UserID: userID, Count: count}
+	if added {
+		uc.publish(roomID, domain.FrameReactionAdd, reaction)
+	}
+	return reaction, nil
+}
+
+// RemoveReaction withdraws a reaction from a message and broadcasts the change
+func (uc *ChatUsecase) RemoveReaction(roomID string, messageID, userID int, emoji string) (*domain.ReactionPayload, error) {
+	if err := uc.checkReactionTarget(roomID, messageID, userID, emoji); err != nil {
+		return nil, err
+	}
+
+	removed, count, err := uc.reactionRepo.RemoveReaction(messageID, userID, emoji)
+	if err != nil {
+		return nil, err
+	}
+
+	reaction := &domain.ReactionPayload{MessageID: messageID, Emoji: emoji, UserID: userID, Count: count}
+	if removed {
+		uc.publish(roomID, domain.FrameReactionDel, reaction)
+	}
+	return reaction, nil
+}
+
+// checkReactionTarget validates the emoji and makes sure the message is live
+// in a room userID has access to
+func (uc *ChatUsecase) checkReactionTarget(roomID string, messageID, userID int, emoji string) error {
+	if emoji == "" || len(emoji) > maxEmojiLength || !utf8.ValidString(emoji) || strings.ContainsAny(emoji, " \t\r\n") {
+		return fmt.Errorf("invalid emoji %q: %w", emoji, domain.ErrInvalidInput)
+	}
+
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return err
+	}
+
+	msg, err := uc.getRoomMessage(roomID, messageID)
+	if err != nil {
+		return err
+	}
+	if msg.DeletedAt != nil {
+		return fmt.Errorf("message %d was deleted: %w", messageID, domain.ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// MarkRead moves the read position of userID in a room forward to the message
+// with the given sequence number or ID, or to the latest message when both are
+// zero. The room is told who read it so that clients can show who has seen
+// a message.
+func (uc *ChatUsecase) MarkRead(roomID string, userID int, seq int64, messageID int) (*domain.ReadState, error) {
+	if seq < 0 || messageID < 0 {
+		return nil, fmt.Errorf("read position is negative: %w", domain.ErrInvalidInput)
+	}
+
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case messageID > 0:
+		msg, err := uc.getRoomMessage(roomID, messageID)
+		if err != nil {
+			return nil, err
+		}
+		seq = msg.Seq
+	case seq == 0:
+		// Capped at the room's latest message by the repository
+		seq = math.MaxInt64
+	}
+
+	state, advanced, err := uc.readStateRepo.MarkRead(roomID, userID, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	if advanced {
+		uc.publish(roomID, domain.FrameRead, domain.ReadPayload{UserID: userID, RoomID: roomID, Seq: state.LastReadSeq})
+	}
+	return state, nil
+}
+
+// GetReadStates lists how far each member of a room has read it
+func (uc *ChatUsecase) GetReadStates(roomID string, userID int) ([]domain.ReadState, error) {
+	if _, err := uc.CheckRoomAccess(roomID, userID); err != nil {
+		return nil, err
+	}
+	return uc.readStateRepo.GetReadStates(roomID)
+}
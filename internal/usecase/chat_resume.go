@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// Replay limits for reconnecting clients
+const (
+	replayBatchSize   = 200
+	maxReplayMessages = 1000
+)
+
+// ResumeClient replays the messages a reconnecting client missed since the
+// last sequence number it saw, then switches it to live delivery. The client
+// must be put on hold with HoldLive before it joins the room, so that live
+// frames arriving during the replay are delivered after it, without
+// duplicates.
+func (uc *ChatUsecase) ResumeClient(client *Client, since int64) error {
+	lastSeq := since
+	defer func() { client.ReleaseLive(lastSeq) }()
+
+	for replayed := 0; replayed < maxReplayMessages; {
+		batch, err := uc.messageRepo.GetMessagesSinceSeq(client.RoomID, lastSeq, min(replayBatchSize, maxReplayMessages-replayed))
+		if err != nil {
+			return fmt.Errorf("error replaying room %s since %d: %w", client.RoomID, since, err)
+		}
+
+		if err := uc.attachMessageDetails(batch); err != nil {
+			return fmt.Errorf("error replaying room %s since %d: %w", client.RoomID, since, err)
+		}
+
+		for _, msg := range batch {
+			frame, err := uc.messageFrame(msg)
+			if err != nil {
+				return fmt.Errorf("error replaying message %d: %w", msg.ID, err)
+			}
+			if !client.SendFrameWait(frame) {
+				return nil // The client went away
+			}
+			lastSeq = msg.Seq
+		}
+
+		replayed += len(batch)
+		if len(batch) < replayBatchSize {
+			return nil
+		}
+	}
+
+	// Too much was missed to replay; the client should reload the history
+	notice, _ := domain.NewFrame(domain.FrameSystem, "", domain.SystemPayload{
+		Event:   domain.SystemResumeTruncated,
+		Message: fmt.Sprintf("Only the first %d missed messages were replayed", maxReplayMessages),
+	})
+	client.SendFrameWait(notice)
+	return nil
+}
@@ -0,0 +1,84 @@
+package usecase
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// Bounds of message searches
+const (
+	maxSearchLength = 256  // Characters of query text
+	maxSearchOffset = 1000 // Hits skipped when paging, deeper pages are too costly to rank
+)
+
+// SearchMessages finds the messages matching a full-text search among the
+// rooms userID may read, best first
+func (uc *ChatUsecase) SearchMessages(userID int, q domain.SearchQuery) (*domain.SearchResult, error) {
+	q.Text = strings.TrimSpace(q.Text)
+	switch {
+	case q.Text == "":
+		return nil, fmt.Errorf("search query is empty: %w", domain.ErrInvalidInput)
+	case len([]rune(q.Text)) > maxSearchLength:
+		return nil, fmt.Errorf("search query is longer than %d characters: %w", maxSearchLength, domain.ErrInvalidInput)
+	case q.Offset < 0 || q.Offset > maxSearchOffset:
+		return nil, fmt.Errorf("search offset must be between 0 and %d: %w", maxSearchOffset, domain.ErrInvalidInput)
+	case q.From != nil && q.To != nil && !q.From.Before(*q.To):
+		return nil, fmt.Errorf("search range is empty: %w", domain.ErrInvalidInput)
+	}
+
+	if q.RoomID != "" {
+		if _, err := uc.CheckRoomAccess(q.RoomID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Message times are stored in UTC
+	if q.From != nil {
+		from := q.From.UTC()
+		q.From = &from
+	}
+	if q.To != nil {
+		to := q.To.UTC()
+		q.To = &to
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	q.Limit = limit + 1
+
+	hits, err := uc.searchRepo.SearchMessages(userID, q, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.SearchResult{Hits: hits}
+	if len(hits) > limit {
+		result.Hits = hits[:limit]
+		next := q.Offset + limit
+		result.NextOffset = &next
+	}
+
+	messages := make([]domain.Message, len(result.Hits))
+	for i, hit := range result.Hits {
+		messages[i] = hit.Message
+	}
+	if err := uc.reactionRepo.AttachReactions(messages, userID); err != nil {
+		return nil, err
+	}
+	if err := uc.attachMessageDetails(messages); err != nil {
+		return nil, err
+	}
+	for i := range result.Hits {
+		result.Hits[i].Message = messages[i]
+	}
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// MaxSlowMode bounds the delay slow mode imposes between messages
+const MaxSlowMode = 6 * time.Hour
+
+// slowModeKey is the Redis key held while a user waits to post again in a room
+func slowModeKey(roomID string, userID int) string {
+	return "chat:slow_mode:" + roomID + ":" + strconv.Itoa(userID)
+}
+
+// SetSlowMode makes members of a room wait the given number of seconds between
+// messages. Zero turns slow mode off. Only room managers may change it.
+func (uc *ChatUsecase) SetSlowMode(roomID string, userID, seconds int) (*domain.Room, error) {
+	if seconds < 0 || time.Duration(seconds)*time.Second > MaxSlowMode {
+		return nil, fmt.Errorf("invalid slow mode of %d seconds: %w", seconds, domain.ErrInvalidInput)
+	}
+
+	room, err := uc.roomRepo.GetRoomByID(roomID)
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.permissions.Check(roomID, userID, PermManageRoom); err != nil {
+		return nil, err
+	}
+
+	if err := uc.roomRepo.SetSlowMode(roomID, seconds); err != nil {
+		return nil, err
+	}
+	room.SlowMode = seconds
+
+	log.Printf("User %d set slow mode of room %s to %d seconds", userID, roomID, seconds)
+	message := "Slow mode is off"
+	if seconds > 0 {
+		message = fmt.Sprintf("Slow mode is on, members may post once every %d seconds", seconds)
+	}
+	uc.announce(roomID, domain.SystemSlowMode, 0, nil, message)
+	return room, nil
+}
+
+// checkSlowMode claims the user's next slot to post in a slow mode room, or
+// returns a RateLimitError telling how long to wait for it. Moderators are
+// not slowed down.
+func (uc *ChatUsecase) checkSlowMode(room *domain.Room, userID int) error {
+	if room.SlowMode <= 0 {
+		return nil
+	}
+
+	role, err := uc.permissions.Role(room.ID, userID)
+	if err != nil {
+		return err
+	}
+	if RoleCan(role, PermBypassSlowMode) {
+		return nil
+	}
+
+	ctx := context.Background()
+	key := slowModeKey(room.ID, userID)
+	claimed, err := uc.redisClient.SetNX(ctx, key, 1, time.Duration(room.SlowMode)*time.Second).Result()
+	if err != nil {
+		return fmt.Errorf("error checking slow mode of room %s: %w", room.ID, err)
+	}
+	if claimed {
+		return nil
+	}
+
+	wsRateLimited.WithLabelValues(rateLimitSlowMode).Inc()
+	wait, err := uc.redisClient.PTTL(ctx, key).Result()
+	if err != nil || wait < 0 {
+		wait = time.Duration(room.SlowMode) * time.Second
+	}
+	return &domain.RateLimitError{RetryAfter: wait}
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// threadReplyLimit caps the number of replies returned with a thread
+const threadReplyLimit = 200
+
+// checkThreadParent makes sure a new message may be posted as a reply to
+// parentID. Threads are one level deep, so replies cannot be replied to.
+func (uc *ChatUsecase) checkThreadParent(roomID string, parentID int) error {
+	parent, err := uc.getRoomMessage(roomID, parentID)
+	if err != nil {
+		return err
+	}
+	if parent.DeletedAt != nil {
+		return fmt.Errorf("message %d was deleted: %w", parentID, domain.ErrNotFound)
+	}
+	if parent.ParentID != nil {
+		return fmt.Errorf("message %d is already a reply: %w", parentID, domain.ErrInvalidInput)
+	}
+	return nil
+}
+
+// publishSaved broadcasts a newly saved message to its room
+func (uc *ChatUsecase) publishSaved(msg domain.Message) {
+	frame, err := uc.messageFrame(msg)
+	if err != nil {
+		log.Printf("Error building frame for message %d: %v", msg.ID, err)
+		return
+	}
+	uc.publishFrame(msg.RoomID, frame)
+}
+
+// messageFrame builds the frame announcing a saved message. Top-level messages
+// go to the room stream while replies are announced as thread events, carrying
+// the updated thread summary so clients need not refetch the room.
+func (uc *ChatUsecase) messageFrame(msg domain.Message) (domain.Frame, error) {
+	var frame domain.Frame
+	var err error
+
+	if msg.ParentID == nil {
+		frame, err = domain.NewFrame(domain.FrameMessage, "", msg)
+	} else {
+		var parent *domain.Message
+		parent, err = uc.messageRepo.GetMessageByID(*msg.ParentID)
+		if err != nil {
+			return domain.Frame{}, fmt.Errorf("error fetching thread %d: %w", *msg.ParentID, err)
+		}
+		frame, err = domain.NewFrame(domain.FrameThreadReply, "", domain.ThreadReplyPayload{
+			ParentID:    parent.ID,
+			ReplyCount:  parent.ReplyCount,
+			LastReplyAt: parent.LastReplyAt,
+			Reply:       msg,
+		})
+	}
+	if err != nil {
+		return domain.Frame{}, err
+	}
+
+	frame.Seq = msg.Seq
+	return frame, nil
+}
+
+// publishThread broadcasts the current summary of a thread to the room
+func (uc *ChatUsecase) publishThread(roomID string, parentID int) {
+	parent, err := uc.messageRepo.GetMessageByID(parentID)
+	if err != nil {
+		log.Printf("Error fetching thread %d: %v", parentID, err)
+		return
+	}
+	uc.publish(roomID, domain.FrameThread, domain.ThreadPayload{
+		ParentID:    parent.ID,
+		ReplyCount:  parent.ReplyCount,
+		LastReplyAt: parent.LastReplyAt,
+	})
+}
+
+// GetThread returns a top-level message of the room together with its replies
+// and their reactions as seen by viewerID
+func (uc *ChatUsecase) GetThread(roomID string, messageID, viewerID int) (*domain.Thread, error) {
+	if _, err := uc.CheckRoomAccess(roomID, viewerID); err != nil {
+		return nil, err
+	}
+
+	parent, err := uc.getRoomMessage(roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.ParentID != nil {
+		return nil, fmt.Errorf("message %d is a reply: %w", messageID, domain.ErrNotFound)
+	}
+
+	replies, err := uc.messageRepo.GetThreadReplies(messageID, threadReplyLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := &domain.Thread{Parent: *parent, Replies: replies}
+	parents := []domain.Message{thread.Parent}
+	if err := uc.reactionRepo.AttachReactions(parents, viewerID); err != nil {
+		return nil, err
+	}
+	if err := uc.attachMessageDetails(parents); err != nil {
+		return nil, err
+	}
+	thread.Parent = parents[0]
+	if err := uc.reactionRepo.AttachReactions(thread.Replies, viewerID); err != nil {
+		return nil, err
+	}
+	if err := uc.attachMessageDetails(thread.Replies); err != nil {
+		return nil, err
+	}
+
+	return thread, nil
+}
@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/workerpool"
+	"github.com/joshbarros/golang-chat-api/pkg/imaging"
+)
+
+// thumbnailTypes are the image types that get thumbnails
+var thumbnailTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+}
+
+// thumbnailKey is the storage key of the thumbnail of the blob at key
+func thumbnailKey(key string) string {
+	return key + ".thumb"
+}
+
+// queueThumbnail queues the generation of an attachment's thumbnail when it
+// is an image and the queue has room for it
+func (uc *ChatUsecase) queueThumbnail(a domain.Attachment) {
+	if uc.attachments.Thumbnails == nil || !thumbnailTypes[a.ContentType] {
+		return
+	}
+
+	// The upload has already been stored, a busy pool only costs the thumbnail
+	queued := uc.attachments.Thumbnails.TryAddTask(workerpool.Task{
+		Name: fmt.Sprintf("thumbnail of attachment %d", a.ID),
+		Run:  func() error { return uc.makeThumbnail(a) },
+	})
+	if !queued {
+		log.Printf("Thumbnail queue is full, skipping attachment %d", a.ID)
+	}
+}
+
+// makeThumbnail decodes an image attachment, stores a thumbnail of it and
+// records its dimensions. Once sent, the room is told about the thumbnail;
+// before that, only the uploader's connections are.
+func (uc *ChatUsecase) makeThumbnail(a domain.Attachment) error {
+	ctx := context.Background()
+
+	blob, err := uc.attachments.Store.Get(ctx, a.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(blob, uc.attachments.MaxSize))
+	blob.Close()
+	if err != nil {
+		return fmt.Errorf("error reading attachment %d: %w", a.ID, err)
+	}
+
+	size := uc.attachments.ThumbnailSize
+	thumb, err := imaging.MakeThumbnail(data, size, size)
+	if err != nil {
+		return fmt.Errorf("error making thumbnail of attachment %d: %w", a.ID, err)
+	}
+
+	key := thumbnailKey(a.StorageKey)
+	if err := uc.attachments.Store.Put(ctx, key, bytes.NewReader(thumb.Data), int64(len(thumb.Data)), thumb.ContentType); err != nil {
+		return err
+	}
+
+	updated, err := uc.attachmentRepo.SetImageInfo(a.ID, thumb.SourceWidth, thumb.SourceHeight, domain.Thumbnail{
+		StorageKey:  key,
+		ContentType: thumb.ContentType,
+		Width:       thumb.Width,
+		Height:      thumb.Height,
+	})
+	if err != nil {
+		if err := uc.attachments.Store.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting orphaned thumbnail %s: %v", key, err)
+		}
+		return err
+	}
+
+	uc.signAttachment(updated)
+	if updated.MessageID == nil {
+		uc.publishToUser(updated.UserID, domain.FrameAttachment, updated)
+	} else {
+		uc.publish(updated.RoomID, domain.FrameAttachment, updated)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// TypingTimeout is how long a user is shown as typing after their last
+// typing.start frame. Clients keep the indicator alive by repeating the frame.
+const TypingTimeout = 8 * time.Second
+
+// typingThrottle is the minimum interval between two typing.start frames
+// announced for the same user and room
+const typingThrottle = 3 * time.Second
+
+// typingState tracks a user typing in a room on this instance
+type typingState struct {
+	timer     *time.Timer
+	gen       int // Incremented on every refresh, so that stale timers are ignored
+	announced time.Time
+}
+
+// StartTyping tells the other members of the room that userID is typing. The
+// indicator stops by itself after TypingTimeout. Repeated calls refresh it but
+// are announced at most once every few seconds. Nothing is persisted.
+func (uc *ChatUsecase) StartTyping(roomID string, userID int) error {
+	if err := uc.checkNotMuted(roomID, userID); err != nil {
+		return err
+	}
+
+	key := memberKey(roomID, userID)
+
+	uc.typingMutex.Lock()
+	state, typing := uc.typing[key]
+	if typing {
+		state.timer.Stop()
+	} else {
+		state = &typingState{}
+		uc.typing[key] = state
+	}
+	state.gen++
+	gen := state.gen
+	state.timer = time.AfterFunc(TypingTimeout, func() {
+		uc.expireTyping(roomID, userID, gen)
+	})
+
+	announce := !typing || time.Since(state.announced) >= typingThrottle
+	if announce {
+		state.announced = time.Now()
+	}
+	uc.typingMutex.Unlock()
+
+	if announce {
+		uc.publishToOthers(roomID, userID, domain.FrameTypingStart, domain.TypingPayload{UserID: userID, RoomID: roomID})
+	}
+	return nil
+}
+
+// StopTyping tells the other members of the room that userID stopped typing
+func (uc *ChatUsecase) StopTyping(roomID string, userID int) {
+	key := memberKey(roomID, userID)
+
+	uc.typingMutex.Lock()
+	state, typing := uc.typing[key]
+	if typing {
+		state.timer.Stop()
+		delete(uc.typing, key)
+	}
+	uc.typingMutex.Unlock()
+
+	if typing {
+		uc.publishToOthers(roomID, userID, domain.FrameTypingStop, domain.TypingPayload{UserID: userID, RoomID: roomID})
+	}
+}
+
+// expireTyping stops the typing indicator of a user who did not refresh it in
+// time. gen identifies the refresh that armed the timer.
+func (uc *ChatUsecase) expireTyping(roomID string, userID, gen int) {
+	key := memberKey(roomID, userID)
+
+	uc.typingMutex.Lock()
+	state, typing := uc.typing[key]
+	expired := typing && state.gen == gen
+	if expired {
+		delete(uc.typing, key)
+	}
+	uc.typingMutex.Unlock()
+
+	if expired {
+		uc.publishToOthers(roomID, userID, domain.FrameTypingStop, domain.TypingPayload{UserID: userID, RoomID: roomID})
+	}
+}
@@ -1,49 +1,154 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/joshbarros/golang-chat-api/internal/domain"
 	"github.com/joshbarros/golang-chat-api/internal/repository"
 	"github.com/joshbarros/golang-chat-api/internal/workerpool"
+	redis_interface "github.com/joshbarros/golang-chat-api/pkg/db/interfaces"
 )
 
 type ChatUsecaseInterface interface {
-	SendMessageToRoom(msg domain.Message) error
-	BroadcastMessages(roomID string, done chan bool)
-	CreateRoom(room *domain.Room) error
-	CloseRoom(roomID string, done chan bool)
-	GetMessagesByRoom(roomID string, limit int) ([]domain.Message, error)
-	GetAvailableRooms() ([]domain.Room, error)
-  GetRoomByID(roomID string) (*domain.Room, error)
-  AddClientToRoom(roomID string, client *websocket.Conn)
-  RemoveClientFromRoom(roomID string, client *websocket.Conn)
-  GetConnectedClients(roomID string) []*websocket.Conn
+	SendMessageToRoom(msg domain.Message, done func(domain.Message, error)) error
+	CreateRoom(room *domain.Room, creatorID int) error
+	JoinRoom(roomID string, userID int) error
+	LeaveRoom(roomID string, userID int) error
+	CheckRoomAccess(roomID string, userID int) (*domain.Room, error)
+	GetRoomMembers(roomID string, userID int) ([]domain.RoomMember, error)
+	OpenDirectRoom(userID, otherID int) (*domain.Room, error)
+	GetDirectRooms(userID int) ([]domain.DirectConversation, error)
+	CreateInvite(roomID string, userID int, ttl time.Duration, maxUses int) (*domain.Invite, error)
+	GetInvites(roomID string, userID int) ([]domain.Invite, error)
+	RevokeInvite(roomID string, inviteID, userID int) (*domain.Invite, error)
+	GetInviteRedemptions(roomID string, inviteID, userID int) ([]domain.InviteRedemption, error)
+	AcceptInvite(token string, userID int) (*domain.Room, error)
+	KickUser(roomID string, actorID, targetID int, reason string) error
+	BanUser(roomID string, actorID, targetID int, duration time.Duration, reason string) (*domain.RoomBan, error)
+	UnbanUser(roomID string, actorID, targetID int) error
+	GetBans(roomID string, userID int) ([]domain.RoomBan, error)
+	MuteUser(roomID string, actorID, targetID int, duration time.Duration, reason string) (*domain.RoomMute, error)
+	UnmuteUser(roomID string, actorID, targetID int) error
+	SetSlowMode(roomID string, userID, seconds int) (*domain.Room, error)
+	StartTyping(roomID string, userID int) error
+	StopTyping(roomID string, userID int)
+	TrackPresence(client *Client)
+	SetPresenceStatus(userID int, status domain.PresenceStatus) (*domain.Presence, error)
+	GetUserPresence(userID int) (*domain.Presence, error)
+	GetRoomPresence(roomID string, userID int) ([]domain.Presence, error)
+	MarkRead(roomID string, userID int, seq int64, messageID int) (*domain.ReadState, error)
+	GetReadStates(roomID string, userID int) ([]domain.ReadState, error)
+	SearchMessages(userID int, q domain.SearchQuery) (*domain.SearchResult, error)
+	MaxAttachmentSize() int64
+	UploadAttachment(roomID string, userID int, filename string, r io.Reader, size int64) (*domain.Attachment, error)
+	GetAttachment(attachmentID, userID int) (*domain.Attachment, error)
+	OpenAttachment(attachmentID int, expires int64, signature string) (*domain.Attachment, io.ReadCloser, error)
+	OpenThumbnail(attachmentID int, expires int64, signature string) (*domain.Thumbnail, io.ReadCloser, error)
+	SetMemberRole(roomID string, actorID, targetID int, role domain.Role) error
+	GetMessagesByRoom(roomID string, viewerID int, page domain.PageRequest) (*domain.MessagePage, error)
+	GetThread(roomID string, messageID, viewerID int) (*domain.Thread, error)
+	AddReaction(roomID string, messageID, userID int, emoji string) (*domain.ReactionPayload, error)
+	RemoveReaction(roomID string, messageID, userID int, emoji string) (*domain.ReactionPayload, error)
+	EditMessage(roomID string, messageID, userID int, text string) (*domain.Message, error)
+	DeleteMessage(roomID string, messageID, userID int) (*domain.Message, error)
+	GetMessageRevisions(roomID string, messageID, userID int) ([]domain.MessageRevision, error)
+	GetAvailableRooms(userID int) ([]domain.RoomSummary, error)
+	GetRoomByID(roomID string) (*domain.Room, error)
+	AddClientToRoom(roomID string, client *Client) error
+	ResumeClient(client *Client, since int64) error
+	RemoveClientFromRoom(roomID string, client *Client)
+	GetConnectedClients(roomID string) []*Client
+}
+
+// maxClientMsgIDLength matches the size of the client_msg_id column
+const maxClientMsgIDLength = 64
+
+// Page sizes for room history
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// subscribeTimeout bounds how long a joining client waits for the instance to
+// subscribe to the room's broadcasts
+const subscribeTimeout = 5 * time.Second
+
+// roomSubscription tracks the broadcaster relaying a room to local clients
+type roomSubscription struct {
+	done  chan bool     // Closed to stop the broadcaster
+	ready chan struct{} // Closed once the broadcaster is subscribed
 }
 
 type ChatUsecase struct {
-	messageRepo *repository.MessageRepository
-	roomRepo    *repository.RoomRepository
-	rooms       map[string]chan domain.Message
-  clients     map[string][]*websocket.Conn
-	roomsMutex  sync.RWMutex
-	workerPool  *workerpool.WorkerPool
+	messageRepo     *repository.MessageRepository
+	roomRepo        *repository.RoomRepository
+	memberRepo      *repository.MemberRepository
+	inviteRepo      *repository.InviteRepository
+	moderationRepo  *repository.ModerationRepository
+	permissions     *Permissions
+	presence        *PresenceTracker
+	reactionRepo    *repository.ReactionRepository
+	readStateRepo   *repository.ReadStateRepository
+	mentionRepo     *repository.MentionRepository
+	searchRepo      *repository.SearchRepository
+	attachmentRepo  *repository.AttachmentRepository
+	attachments     AttachmentConfig
+	linkPreviewRepo *repository.LinkPreviewRepository
+	linkPreviews    LinkPreviewConfig
+	redisClient     redis_interface.RedisClientInterface
+	rooms           map[string]*roomSubscription
+	clients         map[string][]*Client
+	userEvents      *roomSubscription // Relays frames addressed to users, started with the first client
+	roomsMutex      sync.RWMutex
+	typing          map[string]*typingState
+	typingMutex     sync.Mutex
+	workerPool      *workerpool.WorkerPool
 }
 
 func NewChatUsecase(
 	messageRepo *repository.MessageRepository,
 	roomRepo *repository.RoomRepository,
+	memberRepo *repository.MemberRepository,
+	inviteRepo *repository.InviteRepository,
+	moderationRepo *repository.ModerationRepository,
+	reactionRepo *repository.ReactionRepository,
+	readStateRepo *repository.ReadStateRepository,
+	mentionRepo *repository.MentionRepository,
+	searchRepo *repository.SearchRepository,
+	attachmentRepo *repository.AttachmentRepository,
+	attachments AttachmentConfig,
+	linkPreviewRepo *repository.LinkPreviewRepository,
+	linkPreviews LinkPreviewConfig,
 	workerPool *workerpool.WorkerPool,
+	redisClient redis_interface.RedisClientInterface,
 ) *ChatUsecase {
 	return &ChatUsecase{
-		messageRepo: messageRepo,
-		roomRepo:    roomRepo,
-		rooms:       make(map[string]chan domain.Message),
-    clients:     make(map[string][]*websocket.Conn),
-		workerPool:  workerPool,
+		messageRepo:     messageRepo,
+		roomRepo:        roomRepo,
+		memberRepo:      memberRepo,
+		inviteRepo:      inviteRepo,
+		moderationRepo:  moderationRepo,
+		permissions:     NewPermissions(memberRepo),
+		presence:        NewPresenceTracker(redisClient),
+		reactionRepo:    reactionRepo,
+		readStateRepo:   readStateRepo,
+		mentionRepo:     mentionRepo,
+		searchRepo:      searchRepo,
+		attachmentRepo:  attachmentRepo,
+		attachments:     attachments,
+		linkPreviewRepo: linkPreviewRepo,
+		linkPreviews:    linkPreviews,
+		redisClient:     redisClient,
+		rooms:           make(map[string]*roomSubscription),
+		clients:         make(map[string][]*Client),
+		typing:          make(map[string]*typingState),
+		workerPool:      workerPool,
 	}
 }
 
@@ -57,64 +162,173 @@ func (uc *ChatUsecase) GetRoomByID(roomID string) (*domain.Room, error) {
   return room, nil
 }
 
-func (uc *ChatUsecase) GetAvailableRooms() ([]domain.Room, error) {
+// GetAvailableRooms lists the public rooms and the private rooms userID is a
+// member of, with the number of messages userID has not read in each
+func (uc *ChatUsecase) GetAvailableRooms(userID int) ([]domain.RoomSummary, error) {
   // Fetch rooms from the PostgreSQL repository instead of the in-memory map
-  rooms, err := uc.roomRepo.GetRooms()
+  rooms, err := uc.roomRepo.GetRooms(userID)
   if err != nil {
       return nil, fmt.Errorf("error fetching rooms from database: %w", err)
   }
 
-  return rooms, nil
-}
+  roomIDs := make([]string, len(rooms))
+  for i, room := range rooms {
+      roomIDs[i] = room.ID
+  }
 
-func (uc *ChatUsecase) GetMessagesByRoom(roomID string, limit int) ([]domain.Message, error) {
-  return uc.messageRepo.GetMessagesByRoom(roomID, limit)
-}
+  counts, err := uc.readStateRepo.GetUnreadCounts(userID, roomIDs)
+  if err != nil {
+      return nil, err
+  }
 
-// Send message to a room
-func (uc *ChatUsecase) SendMessageToRoom(msg domain.Message) error {
-	uc.workerPool.AddJob(msg)
-	log.Printf("Message sent to worker pool for room: %s", msg.RoomID)
-	return nil
+  summaries := make([]domain.RoomSummary, len(rooms))
+  for i, room := range rooms {
+      summaries[i] = domain.RoomSummary{Room: room, UnreadCount: counts[room.ID]}
+  }
+  return summaries, nil
 }
 
-func (uc *ChatUsecase) BroadcastMessages(roomID string, done chan bool) {
-  uc.roomsMutex.RLock()
-  room, exists := uc.rooms[roomID]
-  uc.roomsMutex.RUnlock()
+// GetMessagesByRoom returns a page of a room's history, newest first, with
+// reactions as seen by viewerID
+func (uc *ChatUsecase) GetMessagesByRoom(roomID string, viewerID int, page domain.PageRequest) (*domain.MessagePage, error) {
+  if _, err := uc.CheckRoomAccess(roomID, viewerID); err != nil {
+      return nil, err
+  }
 
-  if !exists {
-      log.Printf("Room %s does not exist", roomID)
-      return
+  if page.Before != nil && page.After != nil {
+      return nil, fmt.Errorf("before and after are mutually exclusive: %w", domain.ErrInvalidInput)
   }
 
-  // Continuously listen for messages in the room and broadcast them
-  for {
-      select {
-      case msg := <-room:
-          // Save message to DB
-          if err := uc.messageRepo.SaveMessage(msg); err != nil {
-              log.Printf("Error saving message: %v", err)
-          }
+  limit := page.Limit
+  if limit <= 0 {
+      limit = DefaultPageSize
+  }
+  if limit > MaxPageSize {
+      limit = MaxPageSize
+  }
 
-          // Broadcast message to all clients in the room
-          for _, client := range uc.GetConnectedClients(roomID) {
-              if client != nil {
-                  if err := client.WriteJSON(msg); err != nil {
-                      log.Printf("Error broadcasting message to client: %v", err)
-                  }
-              }
-          }
+  // Fetch one extra message to learn whether another page follows
+  messages, err := uc.messageRepo.GetMessagesByRoomCursor(roomID, page.Before, page.After, limit+1)
+  if err != nil {
+      return nil, err
+  }
 
-          log.Printf("Message broadcasted: %s", msg.Message)
-      case <-done:
-          log.Printf("Shutting down room %s", roomID)
-          return
+  hasMore := len(messages) > limit
+  if hasMore {
+      if page.After != nil {
+          messages = messages[1:] // The extra message is the newest one
+      } else {
+          messages = messages[:limit]
       }
   }
+
+  if err := uc.reactionRepo.AttachReactions(messages, viewerID); err != nil {
+      return nil, err
+  }
+  if err := uc.attachMessageDetails(messages); err != nil {
+      return nil, err
+  }
+
+  result := &domain.MessagePage{Messages: messages}
+  if len(messages) > 0 {
+      if page.After != nil || hasMore {
+          result.NextCursor = domain.CursorFor(messages[len(messages)-1]).Encode()
+      }
+      if page.Before != nil || (page.After != nil && hasMore) {
+          result.PrevCursor = domain.CursorFor(messages[0]).Encode()
+      }
+  }
+  return result, nil
 }
 
-func (uc *ChatUsecase) CreateRoom(room *domain.Room) error {
+// Send message to a room. The message is persisted by the worker pool and
+// broadcast to the room once saved; done is then called with the outcome.
+// Messages carrying a client message ID are only ever saved once.
+func (uc *ChatUsecase) SendMessageToRoom(msg domain.Message, done func(domain.Message, error)) error {
+	// Messages carrying attachments may go without text
+	if strings.TrimSpace(msg.Message) == "" && len(msg.Attachments) == 0 {
+		return fmt.Errorf("message is empty: %w", domain.ErrInvalidInput)
+	}
+
+	if len(msg.ClientMsgID) > maxClientMsgIDLength {
+		return fmt.Errorf("client message ID is too long: %w", domain.ErrInvalidInput)
+	}
+
+	room, err := uc.CheckRoomAccess(msg.RoomID, msg.UserID)
+	if err != nil {
+		return err
+	}
+	if err := uc.checkNotMuted(msg.RoomID, msg.UserID); err != nil {
+		return err
+	}
+	if msg.Attachments, err = uc.checkAttachments(msg.RoomID, msg.UserID, msg.Attachments); err != nil {
+		return err
+	}
+
+	// A retried send is answered with the message saved the first time
+	if msg.ClientMsgID != "" {
+		existing, err := uc.messageRepo.GetMessageByClientMsgID(msg.UserID, msg.RoomID, msg.ClientMsgID)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			log.Printf("Duplicate message %s from user %d in room %s", msg.ClientMsgID, msg.UserID, msg.RoomID)
+			if done != nil {
+				done(*existing, nil)
+			}
+			return nil
+		}
+	}
+
+	if msg.ParentID != nil {
+		if err := uc.checkThreadParent(msg.RoomID, *msg.ParentID); err != nil {
+			return err
+		}
+	}
+
+	if err := uc.checkSlowMode(room, msg.UserID); err != nil {
+		return err
+	}
+
+	// Sending a message ends the user's typing indicator
+	uc.StopTyping(msg.RoomID, msg.UserID)
+
+	uc.workerPool.AddJob(workerpool.Job{
+		Message: msg,
+		Done: func(saved domain.Message, err error) {
+			switch {
+			case errors.Is(err, domain.ErrDuplicate):
+				err = nil // Saved by an earlier attempt, which was already broadcast
+			case err == nil:
+				if err := uc.linkAttachments(&saved); err != nil {
+					log.Printf("Error attaching files to message %d: %v", saved.ID, err)
+				}
+				if err := uc.recordMentions(&saved, nil); err != nil {
+					log.Printf("Error recording mentions of message %d: %v", saved.ID, err)
+				}
+				uc.publishSaved(saved)
+				uc.notifyMentions(saved, nil)
+				uc.queueLinkPreviews(saved, "")
+			}
+			if done != nil {
+				done(saved, err)
+			}
+		},
+	})
+	log.Printf("Message sent to worker pool for room: %s", msg.RoomID)
+	return nil
+}
+
+// CreateRoom creates a room owned by its creator
+func (uc *ChatUsecase) CreateRoom(room *domain.Room, creatorID int) error {
+  switch room.Visibility {
+  case "":
+      room.Visibility = domain.RoomPublic
+  case domain.RoomPublic, domain.RoomPrivate:
+  default:
+      return fmt.Errorf("unknown visibility %q: %w", room.Visibility, domain.ErrInvalidInput)
+  }
+
   uc.roomsMutex.Lock()
   defer uc.roomsMutex.Unlock()
 
@@ -130,35 +344,18 @@ func (uc *ChatUsecase) CreateRoom(room *domain.Room) error {
   }
 
   // Create a new room in the database
+  room.CreatedBy = &creatorID
   err = uc.roomRepo.CreateRoom(room) // This should generate the ID
   if err != nil {
       return fmt.Errorf("error creating room in the database: %w", err)
   }
 
-  // Now that the room has an ID, create the room in memory
-  uc.rooms[room.ID] = make(chan domain.Message)
   log.Printf("Room %s created with ID %s", room.RoomName, room.ID)
   return nil
 }
 
-// Close the room
-func (uc *ChatUsecase) CloseRoom(roomID string, done chan bool) {
-	uc.roomsMutex.Lock()
-	defer uc.roomsMutex.Unlock()
-
-	if room, exists := uc.rooms[roomID]; exists {
-		close(room)  // Close the message channel
-		delete(uc.rooms, roomID)  // Remove the room from the map
-		log.Printf("Room %s closed", roomID)
-		done <- true
-	} else {
-		log.Printf("Room %s does not exist", roomID)
-		done <- false
-	}
-}
-
 // getConnectedClients returns all clients connected to a specific room
-func (uc *ChatUsecase) GetConnectedClients(roomID string) []*websocket.Conn {
+func (uc *ChatUsecase) GetConnectedClients(roomID string) []*Client {
   // Return a list of connected WebSocket clients in the room
   uc.roomsMutex.RLock()
   defer uc.roomsMutex.RUnlock()
@@ -170,23 +367,59 @@ func (uc *ChatUsecase) GetConnectedClients(roomID string) []*websocket.Conn {
   return nil
 }
 
-// AddClientToRoom adds a WebSocket connection to a room
-func (uc *ChatUsecase) AddClientToRoom(roomID string, client *websocket.Conn) {
+// AddClientToRoom adds a WebSocket client to a room. The first local
+// client of a room subscribes this instance to the room's broadcasts. It
+// returns once the subscription is live, so that no broadcast published
+// afterwards can be missed by the client. When the subscription cannot be
+// confirmed the client is removed again and an error is returned.
+func (uc *ChatUsecase) AddClientToRoom(roomID string, client *Client) error {
   uc.roomsMutex.Lock()
-  defer uc.roomsMutex.Unlock()
 
   // Initialize room if not already present
   if _, exists := uc.clients[roomID]; !exists {
-      uc.clients[roomID] = []*websocket.Conn{}
+      uc.clients[roomID] = []*Client{}
+  }
+  sub, exists := uc.rooms[roomID]
+  if !exists {
+      sub = &roomSubscription{done: make(chan bool), ready: make(chan struct{})}
+      uc.rooms[roomID] = sub
+      go uc.BroadcastMessages(roomID, sub.done, sub.ready)
   }
+  if uc.userEvents == nil {
+      uc.userEvents = &roomSubscription{done: make(chan bool), ready: make(chan struct{})}
+      go uc.BroadcastUserEvents(uc.userEvents.done, uc.userEvents.ready)
+  }
+  userEvents := uc.userEvents
 
   // Add the WebSocket client to the room
   uc.clients[roomID] = append(uc.clients[roomID], client)
   log.Printf("Client added to room %s", roomID)
+  uc.roomsMutex.Unlock()
+
+  timeout := time.After(subscribeTimeout)
+  for _, ready := range []chan struct{}{sub.ready, userEvents.ready} {
+      select {
+      case <-ready:
+      case <-timeout:
+          uc.RemoveClientFromRoom(roomID, client)
+          return fmt.Errorf("timed out waiting for subscription to room %s", roomID)
+      }
+  }
+
+  // Failed subscriptions are forgotten by their broadcaster
+  uc.roomsMutex.RLock()
+  subscribed := uc.rooms[roomID] == sub && uc.userEvents == userEvents
+  uc.roomsMutex.RUnlock()
+  if !subscribed {
+      uc.RemoveClientFromRoom(roomID, client)
+      return fmt.Errorf("could not subscribe to room %s", roomID)
+  }
+  return nil
 }
 
-// RemoveClientFromRoom removes a WebSocket connection from a room
-func (uc *ChatUsecase) RemoveClientFromRoom(roomID string, client *websocket.Conn) {
+// RemoveClientFromRoom removes a WebSocket client from a room. The room
+// is closed on this instance once its last local client leaves.
+func (uc *ChatUsecase) RemoveClientFromRoom(roomID string, client *Client) {
   uc.roomsMutex.Lock()
   defer uc.roomsMutex.Unlock()
 
@@ -194,10 +427,24 @@ func (uc *ChatUsecase) RemoveClientFromRoom(roomID string, client *websocket.Con
       for i, c := range clients {
           if c == client {
               // Remove the client from the slice
-              uc.clients[roomID] = append(clients[:i], clients[i+1:]...)
+              uc.clients[roomID] = append(clients[:i:i], clients[i+1:]...)
               log.Printf("Client removed from room %s", roomID)
               break
           }
       }
+
+      if len(uc.clients[roomID]) == 0 {
+          delete(uc.clients, roomID)
+          uc.closeRoom(roomID)
+  }
   }
 }
+
+// closeRoom stops the room's broadcaster. Callers must hold roomsMutex.
+func (uc *ChatUsecase) closeRoom(roomID string) {
+	if sub, exists := uc.rooms[roomID]; exists {
+		close(sub.done)
+		delete(uc.rooms, roomID)
+		log.Printf("Room %s closed", roomID)
+	}
+}
@@ -0,0 +1,268 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// ClientConfig controls how frames are written to a WebSocket client and
+// how long it may stay silent before it is disconnected
+type ClientConfig struct {
+	SendBufferSize int           // Frames queued per client before it is evicted
+	WriteTimeout   time.Duration // Deadline for writing a single frame
+	PingInterval   time.Duration // How often the server pings the client
+	PongWait       time.Duration // How long to wait for any frame or pong before giving up
+	IdleTimeout    time.Duration // How long a client may go without sending data frames, pongs aside; 0 for no limit
+	AckTimeout     time.Duration // How long to wait for a delivery ack before resending
+	MaxRetries     int           // Resends of an unacknowledged frame before disconnecting
+}
+
+// Client wraps a WebSocket connection with its own outbound queue. All writes
+// to the connection go through WritePump, so there is only ever one writer.
+type Client struct {
+	UserID int
+	RoomID string
+
+	conn      *websocket.Conn
+	cfg       ClientConfig
+	send      chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+	closeMsg  []byte
+
+	lastActivity atomic.Int64 // Unix nanoseconds of the last data frame read
+
+	// While holding, live frames are parked in pending instead of being
+	// queued, so that missed messages can be replayed ahead of them
+	holdMu  sync.Mutex
+	holding bool
+	pending [][]byte
+
+	// Frames carrying a sequence number that the client has not acknowledged
+	// yet; nil unless the client asked for delivery acks
+	ackMu   sync.Mutex
+	unacked map[int64]*unackedFrame
+}
+
+func NewClient(conn *websocket.Conn, userID int, roomID string, cfg ClientConfig) *Client {
+	c := &Client{
+		UserID: userID,
+		RoomID: roomID,
+		conn:   conn,
+		cfg:    cfg,
+		send:   make(chan []byte, cfg.SendBufferSize),
+		closed: make(chan struct{}),
+	}
+	c.lastActivity.Store(time.Now().UnixNano())
+
+	// Every pong proves the peer is still there, so it extends the deadline
+	conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cfg.PongWait))
+	})
+	return c
+}
+
+// ReadMessage blocks until the next data frame arrives. A client that misses
+// its heartbeat makes the read fail, which tells the caller to drop it.
+func (c *Client) ReadMessage() ([]byte, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			log.Printf("Heartbeat timeout for user %d in room %s", c.UserID, c.RoomID)
+			wsConnectionsClosed.WithLabelValues(closeReasonHeartbeat).Inc()
+		}
+		return nil, err
+	}
+
+	c.lastActivity.Store(time.Now().UnixNano())
+	c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongWait))
+	return data, nil
+}
+
+// Send queues an encoded frame for the client without blocking. A client
+// whose queue is full is considered a slow consumer and is disconnected.
+func (c *Client) Send(data []byte) bool {
+	c.holdMu.Lock()
+	if c.holding {
+		if len(c.pending) >= c.cfg.SendBufferSize {
+			c.holdMu.Unlock()
+			return c.evictSlowConsumer()
+		}
+		c.pending = append(c.pending, data)
+		c.holdMu.Unlock()
+		return true
+	}
+	c.holdMu.Unlock()
+
+	return c.enqueue(data)
+}
+
+// enqueue puts data on the outbound queue without blocking
+func (c *Client) enqueue(data []byte) bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return true
+	default:
+		return c.evictSlowConsumer()
+	}
+}
+
+// evictSlowConsumer disconnects a client that cannot keep up with its room
+func (c *Client) evictSlowConsumer() bool {
+	log.Printf("Send buffer full for user %d in room %s, disconnecting", c.UserID, c.RoomID)
+	wsConnectionsClosed.WithLabelValues(closeReasonSlowConsumer).Inc()
+	c.CloseWithReason(websocket.ClosePolicyViolation, "Slow consumer")
+	return false
+}
+
+// SendFrameWait queues a frame, waiting for room in the outbound queue rather
+// than evicting the client. It is used to replay history at the pace the
+// client can take it.
+func (c *Client) SendFrameWait(frame domain.Frame) bool {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Error encoding %s frame for user %d: %v", frame.Type, c.UserID, err)
+		return false
+	}
+
+	select {
+	case c.send <- data:
+		return true
+	case <-c.closed:
+		return false
+	}
+}
+
+// HoldLive parks live frames until ReleaseLive is called
+func (c *Client) HoldLive() {
+	c.holdMu.Lock()
+	defer c.holdMu.Unlock()
+	c.holding = true
+}
+
+// ReleaseLive queues the parked live frames, dropping the ones announcing
+// messages with a sequence number up to lastSeq, which were already replayed,
+// and resumes live delivery
+func (c *Client) ReleaseLive(lastSeq int64) {
+	c.holdMu.Lock()
+	defer c.holdMu.Unlock()
+
+	for _, data := range c.pending {
+		var frame struct {
+			Seq int64 `json:"seq"`
+		}
+		if err := json.Unmarshal(data, &frame); err == nil && frame.Seq != 0 && frame.Seq <= lastSeq {
+			continue
+		}
+		if !c.enqueue(data) {
+			break
+		}
+	}
+	c.pending = nil
+	c.holding = false
+}
+
+// SendFrame encodes and queues a frame for the client
+func (c *Client) SendFrame(frame domain.Frame) bool {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		log.Printf("Error encoding %s frame for user %d: %v", frame.Type, c.UserID, err)
+		return false
+	}
+	return c.Send(data)
+}
+
+// WritePump writes queued frames and heartbeat pings to the connection until
+// the client is closed
+func (c *Client) WritePump() {
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	var retransmit <-chan time.Time
+	if c.acksRequired() {
+		retransmitTicker := time.NewTicker(c.cfg.AckTimeout / 2)
+		defer retransmitTicker.Stop()
+		retransmit = retransmitTicker.C
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, c.lastActivity.Load()))
+			if c.cfg.IdleTimeout > 0 && idle > c.cfg.IdleTimeout {
+				log.Printf("User %d idle for %s in room %s, disconnecting", c.UserID, idle, c.RoomID)
+				wsConnectionsClosed.WithLabelValues(closeReasonIdle).Inc()
+				c.CloseWithReason(websocket.CloseGoingAway, "Idle timeout")
+				continue
+			}
+
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.WriteTimeout)); err != nil {
+				log.Printf("Error pinging user %d in room %s: %v", c.UserID, c.RoomID, err)
+				wsConnectionsClosed.WithLabelValues(closeReasonHeartbeat).Inc()
+				c.Close()
+				return
+			}
+		case data := <-c.send:
+			// Track before writing, the ack may arrive before the write returns
+			c.trackDelivery(data)
+			if !c.write(data) {
+				return
+			}
+		case <-retransmit:
+			if !c.retransmitUnacked() {
+				return
+			}
+		case <-c.closed:
+			c.conn.WriteControl(websocket.CloseMessage, c.closeMsg, time.Now().Add(c.cfg.WriteTimeout))
+			return
+		}
+	}
+}
+
+// write sends a single frame, closing the client if the write fails
+func (c *Client) write(data []byte) bool {
+	c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+	if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		log.Printf("Error writing to user %d in room %s: %v", c.UserID, c.RoomID, err)
+		c.Close()
+		return false
+	}
+	return true
+}
+
+// Close disconnects the client with a normal closure
+func (c *Client) Close() {
+	c.CloseWithReason(websocket.CloseNormalClosure, "")
+}
+
+// CloseWithReason disconnects the client, sending the given close code
+func (c *Client) CloseWithReason(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closeMsg = websocket.FormatCloseMessage(code, reason)
+		close(c.closed)
+	})
+}
+
+// Done is closed once the client has been disconnected
+func (c *Client) Done() <-chan struct{} {
+	return c.closed
+}
@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// unackedFrame is a frame written to the client that was not acknowledged yet
+type unackedFrame struct {
+	data     []byte
+	sentAt   time.Time
+	attempts int
+}
+
+// RequireAcks makes the client acknowledge every frame carrying a sequence
+// number. Frames left unacknowledged for AckTimeout are written again, and a
+// client that misses MaxRetries acks in a row is disconnected so that it
+// reconnects and resumes from the history, as is one with more than
+// SendBufferSize frames awaiting acks. It must be called before WritePump.
+func (c *Client) RequireAcks() {
+	c.ackMu.Lock()
+	defer c.ackMu.Unlock()
+	c.unacked = make(map[int64]*unackedFrame)
+}
+
+func (c *Client) acksRequired() bool {
+	c.ackMu.Lock()
+	defer c.ackMu.Unlock()
+	return c.unacked != nil
+}
+
+// Ack records that the client received the frames with the given sequence numbers
+func (c *Client) Ack(seqs ...int64) {
+	c.ackMu.Lock()
+	defer c.ackMu.Unlock()
+
+	for _, seq := range seqs {
+		delete(c.unacked, seq)
+	}
+}
+
+// trackDelivery remembers a written frame until the client acknowledges it
+func (c *Client) trackDelivery(data []byte) {
+	c.ackMu.Lock()
+	defer c.ackMu.Unlock()
+
+	if c.unacked == nil {
+		return
+	}
+
+	var frame struct {
+		Seq int64 `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &frame); err != nil || frame.Seq == 0 {
+		return
+	}
+
+	if len(c.unacked) >= c.cfg.SendBufferSize {
+		log.Printf("Too many unacknowledged frames for user %d in room %s", c.UserID, c.RoomID)
+		wsConnectionsClosed.WithLabelValues(closeReasonUnacked).Inc()
+		c.CloseWithReason(websocket.ClosePolicyViolation, "Delivery not acknowledged")
+		return
+	}
+
+	if _, exists := c.unacked[frame.Seq]; !exists {
+		c.unacked[frame.Seq] = &unackedFrame{data: data, sentAt: time.Now()}
+	}
+}
+
+// retransmitUnacked writes again, in sequence order, the frames whose ack is
+// overdue. It returns false if the connection failed.
+func (c *Client) retransmitUnacked() bool {
+	c.ackMu.Lock()
+	var due []int64
+	for seq, frame := range c.unacked {
+		if time.Since(frame.sentAt) >= c.cfg.AckTimeout {
+			due = append(due, seq)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i] < due[j] })
+
+	var resend [][]byte
+	for _, seq := range due {
+		frame := c.unacked[seq]
+		if frame.attempts >= c.cfg.MaxRetries {
+			c.ackMu.Unlock()
+			log.Printf("Frame %d was never acknowledged by user %d in room %s, disconnecting", seq, c.UserID, c.RoomID)
+			wsConnectionsClosed.WithLabelValues(closeReasonUnacked).Inc()
+			c.CloseWithReason(websocket.ClosePolicyViolation, "Delivery not acknowledged")
+			return true
+		}
+		frame.attempts++
+		frame.sentAt = time.Now()
+		resend = append(resend, frame.data)
+	}
+	c.ackMu.Unlock()
+
+	for _, data := range resend {
+		wsDeliveryRetries.Inc()
+		if !c.write(data) {
+			return false
+		}
+	}
+	return true
+}
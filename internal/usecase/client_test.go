@@ -0,0 +1,125 @@
+package usecase_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/joshbarros/golang-chat-api/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = usecase.ClientConfig{
+	SendBufferSize: 2,
+	WriteTimeout:   time.Second,
+	PingInterval:   time.Minute,
+	PongWait:       time.Minute,
+	AckTimeout:     100 * time.Millisecond,
+	MaxRetries:     1,
+}
+
+// connect starts a server wrapping its end of a WebSocket in a Client and
+// returns that client along with the peer connection
+func connect(t *testing.T, cfg usecase.ClientConfig, setup func(*usecase.Client)) (*usecase.Client, *websocket.Conn) {
+	t.Helper()
+
+	clients := make(chan *usecase.Client, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		client := usecase.NewClient(conn, 1, "1", cfg)
+		if setup != nil {
+			setup(client)
+		}
+		go client.WritePump()
+		clients <- client
+	}))
+	t.Cleanup(server.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { peer.Close() })
+
+	return <-clients, peer
+}
+
+func TestClientDeliversQueuedFrames(t *testing.T) {
+	client, peer := connect(t, testConfig, nil)
+
+	assert.True(t, client.Send([]byte(`{"v":1,"type":"message.new"}`)))
+
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := peer.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":1,"type":"message.new"}`, string(data))
+}
+
+func TestClientEvictsSlowConsumer(t *testing.T) {
+	cfg := testConfig
+	client, _ := connect(t, cfg, func(c *usecase.Client) { c.HoldLive() })
+
+	// Parked frames are bounded by the send buffer as well
+	assert.True(t, client.Send([]byte(`{}`)))
+	assert.True(t, client.Send([]byte(`{}`)))
+	assert.False(t, client.Send([]byte(`{}`)))
+
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("slow consumer was not disconnected")
+	}
+}
+
+func TestClientSkipsReplayedFramesOnRelease(t *testing.T) {
+	client, peer := connect(t, testConfig, func(c *usecase.Client) { c.HoldLive() })
+
+	client.Send([]byte(`{"v":1,"type":"message.new","seq":1}`))
+	client.Send([]byte(`{"v":1,"type":"message.new","seq":2}`))
+	client.ReleaseLive(1)
+
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := peer.ReadMessage()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":1,"type":"message.new","seq":2}`, string(data))
+}
+
+func TestClientResendsUnacknowledgedFrames(t *testing.T) {
+	client, peer := connect(t, testConfig, func(c *usecase.Client) { c.RequireAcks() })
+
+	frame := `{"v":1,"type":"message.new","seq":7}`
+	client.Send([]byte(frame))
+
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 2; i++ {
+		_, data, err := peer.ReadMessage()
+		require.NoError(t, err)
+		assert.JSONEq(t, frame, string(data))
+	}
+
+	// The retry budget is spent, so the next missed ack disconnects the client
+	_, _, err := peer.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+}
+
+func TestClientStopsResendingAcknowledgedFrames(t *testing.T) {
+	client, peer := connect(t, testConfig, func(c *usecase.Client) { c.RequireAcks() })
+
+	client.Send([]byte(`{"v":1,"type":"message.new","seq":7}`))
+
+	peer.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := peer.ReadMessage()
+	require.NoError(t, err)
+	client.Ack(7)
+
+	peer.SetReadDeadline(time.Now().Add(3 * testConfig.AckTimeout))
+	_, _, err = peer.ReadMessage()
+	assert.Error(t, err)
+	assert.False(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+}
@@ -0,0 +1,70 @@
+package usecase
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxLinkPreviews is the number of links previewed per message at most
+const maxLinkPreviews = 3
+
+// urlPattern matches http and https links, up to the next space or a
+// character that cannot appear in a URL as written in text
+var urlPattern = regexp.MustCompile("(?i)\\bhttps?://[^\\s<>\"'`]+")
+
+// closingPairs maps the brackets that may end a link to their opening ones
+var closingPairs = map[byte]byte{')': '(', ']': '[', '}': '{'}
+
+// ExtractURLs finds the distinct links in the text of a message, in order of
+// appearance and at most maxLinkPreviews of them. Punctuation ending a
+// sentence and closing brackets that were not opened in the link are not part
+// of it.
+func ExtractURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		link := trimLink(match)
+		u, err := url.Parse(link)
+		if err != nil || u.Host == "" || seen[link] {
+			continue
+		}
+		seen[link] = true
+		urls = append(urls, link)
+		if len(urls) == maxLinkPreviews {
+			break
+		}
+	}
+	return urls
+}
+
+// trimLink removes the trailing characters of a match that belong to the
+// surrounding text
+func trimLink(link string) string {
+	for len(link) > 0 {
+		last := link[len(link)-1]
+		if strings.IndexByte(".,;:!?", last) >= 0 {
+			link = link[:len(link)-1]
+			continue
+		}
+		if open, ok := closingPairs[last]; ok && strings.Count(link, string(open)) < strings.Count(link, string(last)) {
+			link = link[:len(link)-1]
+			continue
+		}
+		break
+	}
+	return link
+}
+
+// sameURLs reports whether two lists of links are equal
+func sameURLs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package usecase_test
+
+import (
+	"testing"
+
+	"github.com/joshbarros/golang-chat-api/internal/usecase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"no links here", nil},
+		{"see https://example.com/a?b=c#d", []string{"https://example.com/a?b=c#d"}},
+		{"Read http://example.com/page.", []string{"http://example.com/page"}},
+		{"(see https://example.com/x)", []string{"https://example.com/x"}},
+		{"https://en.wikipedia.org/wiki/Go_(programming_language)!", []string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{`<a href="https://example.com/q">`, []string{"https://example.com/q"}},
+		{"HTTPS://EXAMPLE.COM twice HTTPS://EXAMPLE.COM", []string{"HTTPS://EXAMPLE.COM"}},
+		{"not a link: xhttps://example.com or ftp://example.com or https://", nil},
+		{"https://a.com https://b.com https://c.com https://d.com", []string{"https://a.com", "https://b.com", "https://c.com"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, usecase.ExtractURLs(tt.text), tt.text)
+	}
+}
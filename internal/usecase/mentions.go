@@ -0,0 +1,42 @@
+package usecase
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+)
+
+// mentionPattern matches an @ followed by a name, unless the @ is part of a
+// word such as an email address
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])(@[\p{L}\p{N}_][\p{L}\p{N}_.-]*)`)
+
+// ParseMentions finds the mentions in the text of a message. User mentions
+// carry the name as written; they are not resolved to users.
+func ParseMentions(text string) []domain.Mention {
+	var mentions []domain.Mention
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2], match[3]
+
+		// Punctuation ending a sentence is not part of the name
+		end = start + len(strings.TrimRight(text[start:end], ".-"))
+		name := text[start+1 : end]
+
+		mention := domain.Mention{
+			Kind:   domain.MentionUser,
+			Offset: utf8.RuneCountInString(text[:start]),
+			Length: utf8.RuneCountInString(text[start:end]),
+		}
+		switch strings.ToLower(name) {
+		case "room":
+			mention.Kind = domain.MentionRoom
+		case "here":
+			mention.Kind = domain.MentionHere
+		default:
+			mention.Username = name
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions
+}
@@ -0,0 +1,62 @@
+package usecase_test
+
+import (
+	"testing"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/usecase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []domain.Mention
+	}{
+		{
+			name: "No mentions",
+			text: "hello world",
+			want: nil,
+		},
+		{
+			name: "User mention",
+			text: "hi @alice",
+			want: []domain.Mention{{Kind: domain.MentionUser, Username: "alice", Offset: 3, Length: 6}},
+		},
+		{
+			name: "Room and here",
+			text: "@room and @HERE",
+			want: []domain.Mention{
+				{Kind: domain.MentionRoom, Offset: 0, Length: 5},
+				{Kind: domain.MentionHere, Offset: 10, Length: 5},
+			},
+		},
+		{
+			name: "Trailing punctuation",
+			text: "thanks @bob.smith.",
+			want: []domain.Mention{{Kind: domain.MentionUser, Username: "bob.smith", Offset: 7, Length: 10}},
+		},
+		{
+			name: "Email address",
+			text: "mail bob@example.com",
+			want: nil,
+		},
+		{
+			name: "Offsets in characters",
+			text: "héllo @zoë",
+			want: []domain.Mention{{Kind: domain.MentionUser, Username: "zoë", Offset: 6, Length: 4}},
+		},
+		{
+			name: "Lone at sign",
+			text: "meet @ 5",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, usecase.ParseMentions(tt.text))
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package usecase
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Reasons recorded when the server closes a WebSocket connection
+const (
+	closeReasonHeartbeat    = "heartbeat"
+	closeReasonIdle         = "idle"
+	closeReasonSlowConsumer = "slow_consumer"
+	closeReasonUnacked      = "unacked"
+	closeReasonRemoved      = "removed"
+)
+
+// Limits recorded when a frame is rejected for being sent too fast
+const (
+	rateLimitFrames   = "frames"
+	rateLimitSlowMode = "slow_mode"
+)
+
+var (
+	wsConnectionsClosed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ws_connections_closed_total",
+			Help: "Total number of WebSocket connections closed by the server",
+		},
+		[]string{"reason"},
+	)
+
+	wsRateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ws_rate_limited_total",
+			Help: "Total number of WebSocket frames rejected by a rate limit",
+		},
+		[]string{"limit"},
+	)
+
+	wsDeliveryRetries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "ws_delivery_retries_total",
+			Help: "Total number of frames written again because the client did not acknowledge them",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(wsConnectionsClosed)
+	prometheus.MustRegister(wsDeliveryRetries)
+	prometheus.MustRegister(wsRateLimited)
+}
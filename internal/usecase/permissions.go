@@ -0,0 +1,133 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/repository"
+)
+
+// Permission is an action in a room that requires a role
+type Permission string
+
+const (
+	PermDeleteAnyMessage Permission = "message.delete_any"
+	PermKick             Permission = "member.kick"
+	PermBan              Permission = "member.ban"
+	PermMute             Permission = "member.mute"
+	PermManageRoles      Permission = "member.roles"
+	PermManageRoom       Permission = "room.manage"
+	PermManageInvites    Permission = "room.invites"
+	PermBypassSlowMode   Permission = "room.slow_mode_exempt"
+)
+
+// roleRanks orders the roles. Users who are not members of a room rank
+// below every role.
+var roleRanks = map[domain.Role]int{
+	domain.RoleMember:    1,
+	domain.RoleModerator: 2,
+	domain.RoleAdmin:     3,
+	domain.RoleOwner:     4,
+}
+
+// rolePermissions lists what each role may do on top of reading and posting
+var rolePermissions = map[domain.Role][]Permission{
+	domain.RoleModerator: {PermDeleteAnyMessage, PermKick, PermMute, PermBypassSlowMode},
+	domain.RoleAdmin: {PermDeleteAnyMessage, PermKick, PermMute, PermBypassSlowMode, PermBan, PermManageRoles,
+		PermManageRoom, PermManageInvites},
+	domain.RoleOwner: {PermDeleteAnyMessage, PermKick, PermMute, PermBypassSlowMode, PermBan, PermManageRoles,
+		PermManageRoom, PermManageInvites},
+}
+
+// ValidRole reports whether role is one of the known roles
+func ValidRole(role domain.Role) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleCan reports whether a role grants a permission
+func RoleCan(role domain.Role, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Outranks reports whether role a ranks strictly above role b
+func Outranks(a, b domain.Role) bool {
+	return roleRanks[a] > roleRanks[b]
+}
+
+// CanAssignRole reports whether a member with role actor may change the role
+// of a member with role target to role. Roles can only be handed out below
+// the actor's own rank, to members ranked below the actor. Ownership can only
+// be transferred by the owner.
+func CanAssignRole(actor, target, role domain.Role) bool {
+	if !RoleCan(actor, PermManageRoles) || !ValidRole(role) {
+		return false
+	}
+	if role == domain.RoleOwner {
+		return actor == domain.RoleOwner && target != domain.RoleOwner
+	}
+	return Outranks(actor, target) && Outranks(actor, role)
+}
+
+// Permissions answers whether users may perform actions in rooms, based on
+// their role in each room
+type Permissions struct {
+	memberRepo *repository.MemberRepository
+}
+
+func NewPermissions(memberRepo *repository.MemberRepository) *Permissions {
+	return &Permissions{memberRepo: memberRepo}
+}
+
+// Role returns the role of userID in a room, or an empty role for non-members
+func (p *Permissions) Role(roomID string, userID int) (domain.Role, error) {
+	return p.memberRepo.GetRole(roomID, userID)
+}
+
+// Check returns ErrForbidden unless userID holds perm in the room
+func (p *Permissions) Check(roomID string, userID int, perm Permission) error {
+	role, err := p.Role(roomID, userID)
+	if err != nil {
+		return err
+	}
+	if !RoleCan(role, perm) {
+		return fmt.Errorf("user %d may not %s in room %s: %w", userID, perm, roomID, domain.ErrForbidden)
+	}
+	return nil
+}
+
+// CheckAgainst returns ErrForbidden unless actorID holds perm in the room and
+// outranks targetID, so that moderators cannot act on admins or each other
+func (p *Permissions) CheckAgainst(roomID string, actorID, targetID int, perm Permission) error {
+	if actorID == targetID {
+		return fmt.Errorf("user %d may not %s themselves: %w", actorID, perm, domain.ErrForbidden)
+	}
+
+	actor, err := p.Role(roomID, actorID)
+	if err != nil {
+		return err
+	}
+	target, err := p.Role(roomID, targetID)
+	if err != nil {
+		return err
+	}
+
+	if !RoleCan(actor, perm) || !Outranks(actor, target) {
+		return fmt.Errorf("user %d may not %s user %d in room %s: %w", actorID, perm, targetID, roomID, domain.ErrForbidden)
+	}
+	return nil
+}
+
+// CanDeleteMessage returns ErrForbidden unless userID wrote the message or
+// may delete any message in its room
+func (p *Permissions) CanDeleteMessage(userID int, msg *domain.Message) error {
+	if msg.UserID == userID {
+		return nil
+	}
+	return p.Check(msg.RoomID, userID, PermDeleteAnyMessage)
+}
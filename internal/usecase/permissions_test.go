@@ -0,0 +1,43 @@
+package usecase_test
+
+import (
+	"testing"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/usecase"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleCan(t *testing.T) {
+	assert.False(t, usecase.RoleCan(domain.RoleMember, usecase.PermDeleteAnyMessage))
+	assert.False(t, usecase.RoleCan("", usecase.PermKick))
+	assert.True(t, usecase.RoleCan(domain.RoleModerator, usecase.PermKick))
+	assert.False(t, usecase.RoleCan(domain.RoleModerator, usecase.PermBan))
+	assert.False(t, usecase.RoleCan(domain.RoleModerator, usecase.PermManageRoles))
+	assert.True(t, usecase.RoleCan(domain.RoleAdmin, usecase.PermBan))
+	assert.True(t, usecase.RoleCan(domain.RoleOwner, usecase.PermManageRoom))
+}
+
+func TestCanAssignRole(t *testing.T) {
+	tests := []struct {
+		name                string
+		actor, target, role domain.Role
+		want                bool
+	}{
+		{"owner promotes member to admin", domain.RoleOwner, domain.RoleMember, domain.RoleAdmin, true},
+		{"owner demotes admin", domain.RoleOwner, domain.RoleAdmin, domain.RoleMember, true},
+		{"owner transfers ownership", domain.RoleOwner, domain.RoleAdmin, domain.RoleOwner, true},
+		{"admin promotes member to moderator", domain.RoleAdmin, domain.RoleMember, domain.RoleModerator, true},
+		{"admin cannot promote to admin", domain.RoleAdmin, domain.RoleMember, domain.RoleAdmin, false},
+		{"admin cannot demote admin", domain.RoleAdmin, domain.RoleAdmin, domain.RoleMember, false},
+		{"admin cannot transfer ownership", domain.RoleAdmin, domain.RoleMember, domain.RoleOwner, false},
+		{"moderator cannot assign roles", domain.RoleModerator, domain.RoleMember, domain.RoleMember, false},
+		{"unknown role", domain.RoleOwner, domain.RoleMember, "superuser", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, usecase.CanAssignRole(tt.actor, tt.target, tt.role))
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	redis_interface "github.com/joshbarros/golang-chat-api/pkg/db/interfaces"
+)
+
+// Presence heartbeats. Every connection refreshes its entry every
+// PresenceHeartbeat; entries of connections lost without a clean close, for
+// instance because their instance crashed, lapse after presenceTTL.
+const (
+	PresenceHeartbeat = 30 * time.Second
+	presenceTTL       = 3 * PresenceHeartbeat
+)
+
+// PresenceTracker records in Redis the WebSocket connections of every user,
+// across all rooms and instances, along with the status users set themselves
+type PresenceTracker struct {
+	redisClient redis_interface.RedisClientInterface
+}
+
+func NewPresenceTracker(redisClient redis_interface.RedisClientInterface) *PresenceTracker {
+	return &PresenceTracker{redisClient: redisClient}
+}
+
+// Redis keys of a user's presence. The connections key is a sorted set of
+// connection IDs scored by the time their entry lapses.
+func presenceConnsKey(userID int) string {
+	return "presence:user:" + strconv.Itoa(userID) + ":conns"
+}
+
+func presenceStatusKey(userID int) string {
+	return "presence:user:" + strconv.Itoa(userID) + ":status"
+}
+
+func presenceLastSeenKey(userID int) string {
+	return "presence:user:" + strconv.Itoa(userID) + ":last_seen"
+}
+
+// presenceOnlineKey is a sorted set of the users holding connections, scored
+// by the time the last of them lapses. It lets lapsed users be swept.
+const presenceOnlineKey = "presence:online"
+
+// scoreAt converts a time to a sorted set score
+func scoreAt(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// Connect records a new connection of the user and returns how many live
+// connections the user now holds
+func (p *PresenceTracker) Connect(userID int, connID string) (int, error) {
+	return p.touch(userID, connID)
+}
+
+// Heartbeat keeps a connection of the user alive
+func (p *PresenceTracker) Heartbeat(userID int, connID string) error {
+	_, err := p.touch(userID, connID)
+	return err
+}
+
+// touch adds or refreshes a connection and counts the live connections
+func (p *PresenceTracker) touch(userID int, connID string) (int, error) {
+	now := time.Now()
+	key := presenceConnsKey(userID)
+
+	expiry := float64(now.Add(presenceTTL).UnixMilli())
+
+	var count *redis.IntCmd
+	_, err := p.redisClient.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(context.Background(), key, "-inf", scoreAt(now))
+		pipe.ZAdd(context.Background(), key, &redis.Z{Score: expiry, Member: connID})
+		pipe.Expire(context.Background(), key, presenceTTL)
+		pipe.ZAdd(context.Background(), presenceOnlineKey, &redis.Z{Score: expiry, Member: strconv.Itoa(userID)})
+		count = pipe.ZCard(context.Background(), key)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error recording presence of user %d: %w", userID, err)
+	}
+	return int(count.Val()), nil
+}
+
+// Disconnect forgets a connection of the user and returns how many live
+// connections the user still holds. The user is last seen now.
+func (p *PresenceTracker) Disconnect(userID int, connID string) (int, error) {
+	now := time.Now()
+	key := presenceConnsKey(userID)
+
+	var count *redis.IntCmd
+	_, err := p.redisClient.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		pipe.ZRem(context.Background(), key, connID)
+		pipe.ZRemRangeByScore(context.Background(), key, "-inf", scoreAt(now))
+		pipe.Set(context.Background(), presenceLastSeenKey(userID), now.Unix(), 0)
+		count = pipe.ZCard(context.Background(), key)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error clearing presence of user %d: %w", userID, err)
+	}
+
+	// A connection opened meanwhile puts the user back with its next heartbeat
+	if count.Val() == 0 {
+		_, err := p.redisClient.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+			pipe.ZRem(context.Background(), presenceOnlineKey, strconv.Itoa(userID))
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error clearing presence of user %d: %w", userID, err)
+		}
+	}
+	return int(count.Val()), nil
+}
+
+// SweepLapsed finds the users whose connections all lapsed without being
+// closed, records when they were last seen and returns them. Every instance
+// may sweep; each lapsed user is returned by only one of them.
+func (p *PresenceTracker) SweepLapsed() ([]int, error) {
+	ctx := context.Background()
+	now := time.Now()
+
+	var due *redis.ZSliceCmd
+	_, err := p.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		due = pipe.ZRangeByScoreWithScores(ctx, presenceOnlineKey, &redis.ZRangeBy{Min: "-inf", Max: scoreAt(now)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching lapsed presence: %w", err)
+	}
+
+	var lapsed []int
+	for _, z := range due.Val() {
+		member, _ := z.Member.(string)
+		userID, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		gone, err := p.sweepUser(ctx, userID, z.Score, now)
+		if err != nil {
+			return lapsed, err
+		}
+		if gone {
+			lapsed = append(lapsed, userID)
+		}
+	}
+	return lapsed, nil
+}
+
+// sweepUser takes a user whose connections lapsed at expiry off the online
+// index and reports whether the user has no live connections left. A user
+// that is still connected is put back.
+func (p *PresenceTracker) sweepUser(ctx context.Context, userID int, expiry float64, now time.Time) (bool, error) {
+	key := presenceConnsKey(userID)
+	member := strconv.Itoa(userID)
+
+	var removed *redis.IntCmd
+	var latest *redis.ZSliceCmd
+	var lastSeen *redis.StringCmd
+	_, err := p.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, presenceOnlineKey, member)
+		pipe.ZRemRangeByScore(ctx, key, "-inf", scoreAt(now))
+		latest = pipe.ZRevRangeWithScores(ctx, key, 0, 0)
+		lastSeen = pipe.Get(ctx, presenceLastSeenKey(userID))
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return false, fmt.Errorf("error sweeping presence of user %d: %w", userID, err)
+	}
+
+	// Another instance got there first
+	if removed.Val() == 0 {
+		return false, nil
+	}
+
+	if conns := latest.Val(); len(conns) > 0 {
+		_, err := p.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZAdd(ctx, presenceOnlineKey, &redis.Z{Score: conns[0].Score, Member: member})
+			return nil
+		})
+		if err != nil {
+			return false, fmt.Errorf("error restoring presence of user %d: %w", userID, err)
+		}
+		return false, nil
+	}
+
+	// The user was last seen at the last heartbeat, unless a connection
+	// closed cleanly after it
+	seen := time.UnixMilli(int64(expiry)).Add(-presenceTTL).Unix()
+	if closed, err := lastSeen.Int64(); err == nil && closed > seen {
+		return true, nil
+	}
+	_, err = p.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, presenceLastSeenKey(userID), seen, 0)
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("error recording when user %d was last seen: %w", userID, err)
+	}
+	return true, nil
+}
+
+// SetStatus records the status chosen by the user. Online clears it.
+func (p *PresenceTracker) SetStatus(userID int, status domain.PresenceStatus) error {
+	_, err := p.redisClient.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		if status == domain.StatusOnline {
+			pipe.Del(context.Background(), presenceStatusKey(userID))
+		} else {
+			pipe.Set(context.Background(), presenceStatusKey(userID), string(status), 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error setting status of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// Get returns the presence of each of the given users, in the same order
+func (p *PresenceTracker) Get(userIDs ...int) ([]domain.Presence, error) {
+	now := scoreAt(time.Now())
+
+	type presenceCmds struct {
+		conns    *redis.IntCmd
+		status   *redis.StringCmd
+		lastSeen *redis.StringCmd
+	}
+	cmds := make([]presenceCmds, len(userIDs))
+
+	_, err := p.redisClient.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		for i, userID := range userIDs {
+			cmds[i].conns = pipe.ZCount(context.Background(), presenceConnsKey(userID), "("+now, "+inf")
+			cmds[i].status = pipe.Get(context.Background(), presenceStatusKey(userID))
+			cmds[i].lastSeen = pipe.Get(context.Background(), presenceLastSeenKey(userID))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("error fetching presence: %w", err)
+	}
+
+	presences := make([]domain.Presence, len(userIDs))
+	for i, userID := range userIDs {
+		presence := domain.Presence{UserID: userID, Status: domain.StatusOffline, Connections: int(cmds[i].conns.Val())}
+		if presence.Connections > 0 {
+			presence.Status = domain.StatusOnline
+			if status := cmds[i].status.Val(); status != "" {
+				presence.Status = domain.PresenceStatus(status)
+			}
+		}
+		if seen, err := cmds[i].lastSeen.Int64(); err == nil {
+			lastSeen := time.Unix(seen, 0).UTC()
+			presence.LastSeen = &lastSeen
+		}
+		presences[i] = presence
+	}
+	return presences, nil
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"golang.org/x/time/rate"
+)
+
+// FrameLimiter throttles the frames users send to rooms with a token bucket
+// per user and room. All connections of a user to a room on this instance
+// share the same bucket.
+type FrameLimiter struct {
+	limit   rate.Limit
+	burst   int
+	mu      sync.Mutex
+	buckets map[string]*frameBucket
+}
+
+// frameBucket is a token bucket and the number of connections using it
+type frameBucket struct {
+	limiter *rate.Limiter
+	conns   int
+}
+
+// NewFrameLimiter allows perSecond frames per second on average with bursts
+// of up to burst frames. A non-positive perSecond disables the limit.
+func NewFrameLimiter(perSecond float64, burst int) *FrameLimiter {
+	limit := rate.Limit(perSecond)
+	if perSecond <= 0 {
+		limit = rate.Inf
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &FrameLimiter{limit: limit, burst: burst, buckets: make(map[string]*frameBucket)}
+}
+
+// memberKey identifies a user in a room
+func memberKey(roomID string, userID int) string {
+	return roomID + ":" + strconv.Itoa(userID)
+}
+
+// Acquire returns the bucket of a user in a room for a new connection. Every
+// call must be matched by a call to Release once the connection closes.
+func (l *FrameLimiter) Acquire(roomID string, userID int) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := memberKey(roomID, userID)
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &frameBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = bucket
+	}
+	bucket.conns++
+	return bucket.limiter
+}
+
+// Release drops the bucket of a user in a room once their last connection
+// to it is closed
+func (l *FrameLimiter) Release(roomID string, userID int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := memberKey(roomID, userID)
+	if bucket, exists := l.buckets[key]; exists {
+		bucket.conns--
+		if bucket.conns <= 0 {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Throttle takes a token from the bucket, or returns a RateLimitError telling
+// how long to wait for the next one
+func Throttle(limiter *rate.Limiter) error {
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		wsRateLimited.WithLabelValues(rateLimitFrames).Inc()
+		return &domain.RateLimitError{}
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		wsRateLimited.WithLabelValues(rateLimitFrames).Inc()
+		return &domain.RateLimitError{RetryAfter: delay}
+	}
+	return nil
+}
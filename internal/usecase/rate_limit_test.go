@@ -0,0 +1,46 @@
+package usecase_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joshbarros/golang-chat-api/internal/domain"
+	"github.com/joshbarros/golang-chat-api/internal/usecase"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameLimiterSharesBucketPerUserAndRoom(t *testing.T) {
+	limiter := usecase.NewFrameLimiter(1, 2)
+
+	first := limiter.Acquire("1", 7)
+	second := limiter.Acquire("1", 7)
+	assert.Same(t, first, second)
+	assert.NotSame(t, first, limiter.Acquire("1", 8))
+	assert.NotSame(t, first, limiter.Acquire("2", 7))
+
+	// The bucket outlives the first connection to close
+	limiter.Release("1", 7)
+	assert.Same(t, first, limiter.Acquire("1", 7))
+}
+
+func TestThrottle(t *testing.T) {
+	bucket := usecase.NewFrameLimiter(1, 2).Acquire("1", 7)
+
+	require.NoError(t, usecase.Throttle(bucket))
+	require.NoError(t, usecase.Throttle(bucket))
+
+	err := usecase.Throttle(bucket)
+	assert.True(t, errors.Is(err, domain.ErrRateLimited))
+
+	var rateLimit *domain.RateLimitError
+	require.True(t, errors.As(err, &rateLimit))
+	assert.Greater(t, rateLimit.RetryAfter.Milliseconds(), int64(0))
+}
+
+func TestThrottleDisabled(t *testing.T) {
+	bucket := usecase.NewFrameLimiter(0, 1).Acquire("1", 7)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, usecase.Throttle(bucket))
+	}
+}
@@ -0,0 +1,65 @@
+package workerpool
+
+import (
+	"log"
+)
+
+// Task is a unit of background work, such as processing an upload
+type Task struct {
+	Name string // Describes the task in logs
+	Run  func() error
+}
+
+// TaskPool runs background tasks on a fixed number of workers, in the order
+// they were queued
+type TaskPool struct {
+	taskQueue chan Task
+}
+
+func NewTaskPool(numWorkers, queueSize int) *TaskPool {
+	tp := &TaskPool{
+		taskQueue: make(chan Task, queueSize),
+	}
+
+	// Launch workers
+	for i := 0; i < numWorkers; i++ {
+		go tp.worker(i)
+	}
+	return tp
+}
+
+func (tp *TaskPool) worker(id int) {
+	for task := range tp.taskQueue {
+		tp.run(id, task)
+	}
+}
+
+// run runs a single task. A task that panics is logged and does not take its
+// worker down.
+func (tp *TaskPool) run(id int, task Task) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Task worker %d panicked running %s: %v", id, task.Name, r)
+		}
+	}()
+
+	if err := task.Run(); err != nil {
+		log.Printf("Task worker %d failed to run %s: %v", id, task.Name, err)
+	}
+}
+
+// AddTask queues a task, waiting while the queue is full
+func (tp *TaskPool) AddTask(task Task) {
+	tp.taskQueue <- task
+}
+
+// TryAddTask queues a task unless the queue is full, and reports whether it
+// was queued
+func (tp *TaskPool) TryAddTask(task Task) bool {
+	select {
+	case tp.taskQueue <- task:
+		return true
+	default:
+		return false
+	}
+}
@@ -7,14 +7,21 @@ import (
 	"github.com/joshbarros/golang-chat-api/internal/repository"
 )
 
+// Job is a message waiting to be persisted. Done, when set, is called by the
+// worker with the saved message (including its generated ID) or the error.
+type Job struct {
+	Message domain.Message
+	Done    func(msg domain.Message, err error)
+}
+
 type WorkerPool struct {
-	jobQueue    chan domain.Message
+	jobQueue    chan Job
 	messageRepo *repository.MessageRepository
 }
 
 func NewWorkerPool(numWorkers int, messageRepo *repository.MessageRepository) *WorkerPool {
 	wp := &WorkerPool{
-		jobQueue:    make(chan domain.Message, 100), // Queue size of 100
+		jobQueue:    make(chan Job, 100), // Queue size of 100
 		messageRepo: messageRepo,
 	}
 
@@ -27,20 +34,27 @@ func NewWorkerPool(numWorkers int, messageRepo *repository.MessageRepository) *W
 
 func (wp *WorkerPool) worker(id int) {
 	// Workers listen on the global jobQueue
-	for msg := range wp.jobQueue {
+	for job := range wp.jobQueue {
+		msg := job.Message
+
 		// Log the message before processing
 		log.Printf("Worker %d processing message from user %d in room %s: %s", id, msg.UserID, msg.RoomID, msg.Message)
 
 		// Save the message to the database
-		if err := wp.messageRepo.SaveMessage(msg); err != nil {
+		err := wp.messageRepo.SaveMessage(&msg)
+		if err != nil {
 			log.Printf("Worker %d failed to save message from user %d in room %s: %v", id, msg.UserID, msg.RoomID, err)
 		} else {
 			log.Printf("Worker %d successfully saved message from user %d in room %s", id, msg.UserID, msg.RoomID)
 		}
+
+		if job.Done != nil {
+			job.Done(msg, err)
+		}
 	}
 }
 
-func (wp *WorkerPool) AddJob(msg domain.Message) {
-	wp.jobQueue <- msg
-	log.Printf("Job added to worker pool for user %d in room %s", msg.UserID, msg.RoomID)
+func (wp *WorkerPool) AddJob(job Job) {
+	wp.jobQueue <- job
+	log.Printf("Job added to worker pool for user %d in room %s", job.Message.UserID, job.Message.RoomID)
 }
@@ -0,0 +1,166 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// JPEG markers
+const (
+	markerSOI   = 0xD8 // Start of image
+	markerSOS   = 0xDA // Start of scan, the entropy-coded data follows
+	markerAPP0  = 0xE0 // JFIF header
+	markerAPP1  = 0xE1 // Exif and XMP metadata
+	markerAPP13 = 0xED // Photoshop and IPTC metadata
+	markerCOM   = 0xFE // Comment
+)
+
+// exifHeader starts the payload of an Exif APP1 segment
+var exifHeader = []byte("Exif\x00\x00")
+
+// orientationTag is the Exif tag telling how the image must be rotated or
+// flipped for display
+const orientationTag = 0x0112
+
+// ErrNotJPEG is returned when data does not hold a JPEG image
+var ErrNotJPEG = errors.New("not a JPEG image")
+
+// segment is a marker segment of a JPEG file, before the scan data
+type segment struct {
+	marker byte
+	data   []byte // The whole segment, marker included
+}
+
+// splitJPEG returns the marker segments of a JPEG file up to the first scan,
+// and the rest of the file starting with the scan
+func splitJPEG(data []byte) ([]segment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, nil, ErrNotJPEG
+	}
+
+	var segments []segment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, nil, ErrNotJPEG
+		}
+		marker := data[pos+1]
+		switch {
+		case marker == 0xFF:
+			pos++ // Fill byte
+			continue
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			segments = append(segments, segment{marker: marker, data: data[pos : pos+2]})
+			pos += 2
+			continue
+		case marker == markerSOS:
+			return segments, data[pos:], nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return nil, nil, ErrNotJPEG
+		}
+		segments = append(segments, segment{marker: marker, data: data[pos : pos+2+length]})
+		pos += 2 + length
+	}
+	return nil, nil, ErrNotJPEG
+}
+
+// JPEGOrientation returns the Exif orientation of a JPEG image, from 1 to 8,
+// or 1 when it has none
+func JPEGOrientation(data []byte) int {
+	segments, _, err := splitJPEG(data)
+	if err != nil {
+		return 1
+	}
+	for _, seg := range segments {
+		if seg.marker != markerAPP1 || !bytes.HasPrefix(seg.data[4:], exifHeader) {
+			continue
+		}
+		if o := exifOrientation(seg.data[4+len(exifHeader):]); o != 0 {
+			return o
+		}
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the first IFD of a TIFF
+// structure, returning 0 when it is missing or invalid
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == orientationTag {
+			o := int(order.Uint16(tiff[entry+8:]))
+			if o < 1 || o > 8 {
+				return 0
+			}
+			return o
+		}
+	}
+	return 0
+}
+
+// orientationSegment builds an Exif APP1 segment holding only the orientation
+func orientationSegment(orientation int) []byte {
+	seg := []byte{0xFF, markerAPP1, 0, 34}
+	seg = append(seg, exifHeader...)
+	seg = append(seg, 'M', 'M', 0, 42, 0, 0, 0, 8) // Big endian TIFF header, IFD at offset 8
+	seg = append(seg, 0, 1)                        // One entry
+	seg = append(seg, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, byte(orientation), 0, 0)
+	seg = append(seg, 0, 0, 0, 0) // No next IFD
+	return seg
+}
+
+// StripJPEGMetadata removes the Exif, XMP, IPTC and comment segments of a
+// JPEG file without re-encoding the image. The orientation, which is needed
+// to display the image the right way up, is kept in a minimal Exif segment.
+func StripJPEGMetadata(data []byte) ([]byte, error) {
+	segments, scan, err := splitJPEG(data)
+	if err != nil {
+		return nil, err
+	}
+	orientation := JPEGOrientation(data)
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, markerSOI)
+	inserted := orientation == 1
+	for _, seg := range segments {
+		switch seg.marker {
+		case markerAPP1, markerAPP13, markerCOM:
+			continue
+		}
+		// The JFIF header must stay first
+		if !inserted && seg.marker != markerAPP0 {
+			out = append(out, orientationSegment(orientation)...)
+			inserted = true
+		}
+		out = append(out, seg.data...)
+	}
+	if !inserted {
+		out = append(out, orientationSegment(orientation)...)
+	}
+	return append(out, scan...), nil
+}
@@ -0,0 +1,143 @@
+package imaging_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/joshbarros/golang-chat-api/pkg/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFit(t *testing.T) {
+	w, h := imaging.Fit(1000, 500, 320, 320)
+	assert.Equal(t, []int{320, 160}, []int{w, h})
+
+	w, h = imaging.Fit(500, 1000, 320, 320)
+	assert.Equal(t, []int{160, 320}, []int{w, h})
+
+	// Small images keep their size, thin ones keep at least a pixel
+	w, h = imaging.Fit(100, 50, 320, 320)
+	assert.Equal(t, []int{100, 50}, []int{w, h})
+	w, h = imaging.Fit(10000, 2, 320, 320)
+	assert.Equal(t, []int{320, 1}, []int{w, h})
+}
+
+func TestResizeAverages(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	src.SetRGBA(0, 0, color.RGBA{R: 255, A: 255})
+	src.SetRGBA(1, 0, color.RGBA{R: 255, A: 255})
+	src.SetRGBA(0, 1, color.RGBA{B: 255, A: 255})
+	src.SetRGBA(1, 1, color.RGBA{B: 255, A: 255})
+
+	dst := imaging.Resize(src, 1, 1)
+	assert.Equal(t, color.RGBA{R: 127, B: 127, A: 255}, dst.RGBAAt(0, 0))
+}
+
+func TestOrient(t *testing.T) {
+	// A 2x1 image: red then blue
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.SetRGBA(0, 0, red)
+	src.SetRGBA(1, 0, blue)
+
+	mirrored := imaging.Orient(src, 2)
+	assert.Equal(t, blue, mirrored.RGBAAt(0, 0))
+
+	// Turned 90° clockwise for display, red ends up on top
+	turned := imaging.Orient(src, 6)
+	assert.Equal(t, image.Rect(0, 0, 1, 2), turned.Bounds())
+	assert.Equal(t, red, turned.RGBAAt(0, 0))
+	assert.Equal(t, blue, turned.RGBAAt(0, 1))
+
+	// Turned 90° counter-clockwise, blue ends up on top
+	turned = imaging.Orient(src, 8)
+	assert.Equal(t, blue, turned.RGBAAt(0, 0))
+	assert.Equal(t, red, turned.RGBAAt(0, 1))
+}
+
+// encodeJPEG returns a JPEG image of the given size
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil))
+	return buf.Bytes()
+}
+
+// withMetadata inserts after the SOI marker of a JPEG image a little endian
+// Exif segment with the given orientation and a GPS-like tag, and a comment
+func withMetadata(data []byte, orientation byte) []byte {
+	exif := []byte("Exif\x00\x00")
+	exif = append(exif, 'I', 'I', 42, 0, 8, 0, 0, 0) // TIFF header, IFD at 8
+	exif = append(exif, 2, 0)                        // Two entries
+	exif = append(exif, 0x12, 0x01, 3, 0, 1, 0, 0, 0, orientation, 0, 0, 0)
+	exif = append(exif, 0x25, 0x88, 4, 0, 1, 0, 0, 0, 'G', 'P', 'S', '!')
+	exif = append(exif, 0, 0, 0, 0)
+
+	comment := []byte("taken at home")
+
+	out := []byte{0xFF, 0xD8}
+	out = append(out, 0xFF, 0xE1, byte((len(exif)+2)>>8), byte(len(exif)+2))
+	out = append(out, exif...)
+	out = append(out, 0xFF, 0xFE, byte((len(comment)+2)>>8), byte(len(comment)+2))
+	out = append(out, comment...)
+	return append(out, data[2:]...)
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	data := withMetadata(encodeJPEG(t, 8, 4), 6)
+	require.Equal(t, 6, imaging.JPEGOrientation(data))
+
+	stripped, err := imaging.StripJPEGMetadata(data)
+	require.NoError(t, err)
+	assert.NotContains(t, string(stripped), "GPS!")
+	assert.NotContains(t, string(stripped), "taken at home")
+
+	// The orientation survives and the image still decodes
+	assert.Equal(t, 6, imaging.JPEGOrientation(stripped))
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(stripped))
+	require.NoError(t, err)
+	assert.Equal(t, []int{8, 4}, []int{cfg.Width, cfg.Height})
+
+	// Without an orientation no Exif segment is left at all
+	stripped, err = imaging.StripJPEGMetadata(withMetadata(encodeJPEG(t, 8, 4), 1))
+	require.NoError(t, err)
+	assert.NotContains(t, string(stripped), "Exif")
+
+	_, err = imaging.StripJPEGMetadata([]byte("not a jpeg"))
+	assert.ErrorIs(t, err, imaging.ErrNotJPEG)
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 640, 480))))
+	thumb, err := imaging.MakeThumbnail(buf.Bytes(), 320, 320)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", thumb.ContentType)
+	assert.Equal(t, []int{320, 240, 640, 480}, []int{thumb.Width, thumb.Height, thumb.SourceWidth, thumb.SourceHeight})
+
+	// JPEG images are turned the right way up, sizes are reported as displayed
+	thumb, err = imaging.MakeThumbnail(withMetadata(encodeJPEG(t, 800, 400), 6), 320, 320)
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", thumb.ContentType)
+	assert.Equal(t, []int{160, 320, 400, 800}, []int{thumb.Width, thumb.Height, thumb.SourceWidth, thumb.SourceHeight})
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb.Data))
+	require.NoError(t, err)
+	assert.Equal(t, []int{160, 320}, []int{cfg.Width, cfg.Height})
+	assert.Equal(t, 1, imaging.JPEGOrientation(thumb.Data))
+
+	buf.Reset()
+	palette := color.Palette{color.Transparent, color.Black}
+	require.NoError(t, gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 100, 50), palette), nil))
+	thumb, err = imaging.MakeThumbnail(buf.Bytes(), 320, 320)
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", thumb.ContentType)
+	assert.Equal(t, []int{100, 50}, []int{thumb.Width, thumb.Height})
+
+	_, err = imaging.MakeThumbnail([]byte("not an image"), 320, 320)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// Fit returns the largest size with the aspect ratio of width x height that
+// fits in maxWidth x maxHeight. Images that already fit keep their size.
+func Fit(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= maxWidth && height <= maxHeight {
+		return width, height
+	}
+	if width*maxHeight > height*maxWidth {
+		return maxWidth, max(1, height*maxWidth/width)
+	}
+	return max(1, width*maxHeight/height), maxHeight
+}
+
+// Resize scales src to width x height, averaging the source pixels covered
+// by each destination pixel
+func Resize(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for dy := 0; dy < height; dy++ {
+		y0 := dy * srcH / height
+		y1 := max((dy+1)*srcH/height, y0+1)
+		for dx := 0; dx < width; dx++ {
+			x0 := dx * srcW / width
+			x1 := max((dx+1)*srcW/width, x0+1)
+
+			var r, g, b, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := src.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// swapsAxes reports whether an Exif orientation turns the image on its side
+func swapsAxes(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// Orient rotates and flips src as its Exif orientation says, so that it is
+// the right way up
+func Orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if swapsAxes(orientation) {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored
+				sx, sy = w-1-x, y
+			case 3: // Upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // Mirrored upside down
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Turned 90° counter-clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Turned 90° clockwise
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, src.RGBAAt(src.Bounds().Min.X+sx, src.Bounds().Min.Y+sy))
+		}
+	}
+	return dst
+}
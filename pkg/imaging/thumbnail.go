@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // Register the GIF decoder
+	"image/jpeg"
+	"image/png"
+)
+
+// MaxPixels bounds the size of the images decoded, so that a small file
+// cannot expand into a huge bitmap
+const MaxPixels = 24_000_000
+
+// thumbnailQuality is the JPEG quality of thumbnails
+const thumbnailQuality = 80
+
+// ErrTooManyPixels is returned for images larger than MaxPixels
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// Thumbnail is a scaled down copy of an image
+type Thumbnail struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+	// Size of the original image the right way up
+	SourceWidth  int
+	SourceHeight int
+}
+
+// MakeThumbnail decodes a PNG, JPEG or GIF image and scales it down to fit in
+// maxWidth x maxHeight, turning it the right way up. The thumbnail is encoded
+// afresh, so it carries no metadata. JPEG images give JPEG thumbnails and the
+// others PNG thumbnails, which keep transparency. Only the first frame of
+// animated GIFs is used.
+func MakeThumbnail(data []byte, maxWidth, maxHeight int) (*Thumbnail, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error reading image header: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s image: %w", format, err)
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = JPEGOrientation(data)
+	}
+
+	// Scale first and turn the smaller image
+	width, height := cfg.Width, cfg.Height
+	if swapsAxes(orientation) {
+		width, height = height, width
+	}
+	thumbWidth, thumbHeight := Fit(width, height, maxWidth, maxHeight)
+	if swapsAxes(orientation) {
+		thumbWidth, thumbHeight = thumbHeight, thumbWidth
+	}
+	thumb := Orient(Resize(img, thumbWidth, thumbHeight), orientation)
+
+	var buf bytes.Buffer
+	contentType := "image/png"
+	if format == "jpeg" {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: thumbnailQuality})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+
+	return &Thumbnail{
+		Data:         buf.Bytes(),
+		ContentType:  contentType,
+		Width:        thumb.Bounds().Dx(),
+		Height:       thumb.Bounds().Dy(),
+		SourceWidth:  width,
+		SourceHeight: height,
+	}, nil
+}
@@ -0,0 +1,15 @@
+package security
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// RandomToken returns a URL-safe random token encoding n bytes of entropy
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
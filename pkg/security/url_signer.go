@@ -0,0 +1,41 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrInvalidSignature is returned for signed URLs that were tampered with or
+// have expired
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// URLSigner signs URL paths so that they can be shared without
+// authentication until they expire
+type URLSigner struct {
+	key []byte
+}
+
+func NewURLSigner(key []byte) *URLSigner {
+	return &URLSigner{key: key}
+}
+
+// Sign returns the signature of path valid until expires
+func (s *URLSigner) Sign(path string, expires time.Time) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(expires.Unix(), 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks that signature was made by Sign for path and expires, and
+// that expires, a Unix time, has not passed
+func (s *URLSigner) Verify(path string, expires int64, signature string, now time.Time) error {
+	expected := s.Sign(path, time.Unix(expires, 0))
+	if !hmac.Equal([]byte(signature), []byte(expected)) || now.Unix() >= expires {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package security_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joshbarros/golang-chat-api/pkg/security"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := security.NewURLSigner([]byte("secret"))
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Minute)
+	signature := signer.Sign("/attachments/1/download", expires)
+
+	assert.NoError(t, signer.Verify("/attachments/1/download", expires.Unix(), signature, now))
+
+	// Another path, a later expiry or another key do not match
+	assert.ErrorIs(t, signer.Verify("/attachments/2/download", expires.Unix(), signature, now), security.ErrInvalidSignature)
+	assert.ErrorIs(t, signer.Verify("/attachments/1/download", expires.Unix()+1, signature, now), security.ErrInvalidSignature)
+	other := security.NewURLSigner([]byte("other"))
+	assert.ErrorIs(t, other.Verify("/attachments/1/download", expires.Unix(), signature, now), security.ErrInvalidSignature)
+
+	// Expired
+	assert.ErrorIs(t, signer.Verify("/attachments/1/download", expires.Unix(), signature, expires), security.ErrInvalidSignature)
+}
@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrBlobNotFound is returned when no blob is stored under a key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores opaque blobs under keys made of slash-separated segments
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any blob stored
+	// there
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key, if any
+	Delete(ctx context.Context, key string) error
+}
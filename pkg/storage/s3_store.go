@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config locates a bucket of an S3-compatible service such as MinIO
+type S3Config struct {
+	Endpoint  string // Base URL of the service, e.g. http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store keeps blobs as objects of an S3 bucket, addressed path-style so that
+// it works with any S3-compatible service
+type S3Store struct {
+	endpoint *url.URL
+	bucket   string
+	creds    credentials
+	client   *http.Client
+}
+
+// NewS3Store returns a store keeping blobs in the configured bucket
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is not set")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Store{
+		endpoint: endpoint,
+		bucket:   cfg.Bucket,
+		creds: credentials{
+			accessKey: cfg.AccessKey,
+			secretKey: cfg.SecretKey,
+			region:    region,
+			service:   "s3",
+		},
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// objectURL returns the URL of the object stored under key, or of the bucket
+// itself when key is empty
+func (s *S3Store) objectURL(key string) string {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	if key != "" {
+		u.Path += "/" + key
+	}
+	return u.String()
+}
+
+// do signs and sends a request, returning the response if its status is one
+// of the expected ones
+func (s *S3Store) do(req *http.Request, payloadHash string, expected ...int) (*http.Response, error) {
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	s.creds.sign(req, payloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrBlobNotFound
+	}
+	return nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// EnsureBucket creates the bucket unless it already exists
+func (s *S3Store) EnsureBucket(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(""), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash, http.StatusOK, http.StatusConflict)
+	if err != nil {
+		return fmt.Errorf("error creating bucket %s: %w", s.bucket, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Put streams the blob to the service without hashing it first
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req, unsignedPayload, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("error storing blob %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching blob %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// Delete succeeds whether or not the object exists, like S3 itself
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req, emptyPayloadHash, http.StatusOK, http.StatusNoContent, http.StatusNotFound)
+	if err != nil {
+		return fmt.Errorf("error deleting blob %s: %w", key, err)
+	}
+	resp.Body.Close()
+	return nil
+}
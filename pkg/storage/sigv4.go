@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of the dates used by AWS Signature Version 4
+const (
+	amzDateFormat   = "20060102T150405Z"
+	amzShortDateFmt = "20060102"
+)
+
+// unsignedPayload is sent in place of the payload hash when the body is
+// streamed without being hashed first
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// emptyPayloadHash is the SHA-256 of an empty body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// credentials sign requests with AWS Signature Version 4
+type credentials struct {
+	accessKey string
+	secretKey string
+	region    string
+	service   string
+}
+
+// sign adds the X-Amz-Date and Authorization headers to req. The Host header
+// and every X-Amz-* header already set are signed. payloadHash is the hex
+// SHA-256 of the body or unsignedPayload; it must match the
+// X-Amz-Content-Sha256 header for S3.
+func (c credentials) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" {
+			headers[lower] = strings.Join(values, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(amzShortDateFmt), c.region, c.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(c.signingKey(now), stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// signingKey derives the key signing the requests of a day
+func (c credentials) signingKey(now time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), now.UTC().Format(amzShortDateFmt))
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, c.service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// canonicalPath URI-encodes every segment of the path
+func canonicalPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			unescaped = segment
+		}
+		segments[i] = uriEncode(unescaped)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery sorts the query parameters by name and value
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte except the unreserved characters
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The expected values come from the examples of the AWS Signature Version 4
+// documentation
+var exampleCredentials = credentials{
+	accessKey: "AKIDEXAMPLE",
+	secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	region:    "us-east-1",
+	service:   "iam",
+}
+
+var exampleTime = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+func TestSigningKey(t *testing.T) {
+	assert.Equal(t, "c4afb1cc5771d871763a393e44b703571b55cc28424d1a5e86da6ed3c154a4b9",
+		hex.EncodeToString(exampleCredentials.signingKey(exampleTime)))
+}
+
+func TestSign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	exampleCredentials.sign(req, emptyPayloadHash, exampleTime)
+
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date, "+
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		req.Header.Get("Authorization"))
+}
+
+func TestURIEncode(t *testing.T) {
+	assert.Equal(t, "a-b_c.d~e", uriEncode("a-b_c.d~e"))
+	assert.Equal(t, "caf%C3%A9%20%2F%2B", uriEncode("café /+"))
+}
@@ -0,0 +1,124 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/joshbarros/golang-chat-api/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 serves the object API of an S3 bucket from memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// testStore stores, reads back and deletes a blob
+func testStore(t *testing.T, store storage.BlobStore) {
+	ctx := context.Background()
+	data := "hello blob"
+
+	require.NoError(t, store.Put(ctx, "rooms/1/abc", strings.NewReader(data), int64(len(data)), "text/plain"))
+
+	r, err := store.Get(ctx, "rooms/1/abc")
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	r.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, string(got))
+
+	require.NoError(t, store.Delete(ctx, "rooms/1/abc"))
+	_, err = store.Get(ctx, "rooms/1/abc")
+	assert.True(t, errors.Is(err, storage.ErrBlobNotFound))
+
+	// Deleting a missing blob is not an error
+	assert.NoError(t, store.Delete(ctx, "rooms/1/abc"))
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	testStore(t, store)
+
+	t.Run("Rejects keys escaping the root", func(t *testing.T) {
+		for _, key := range []string{"../secret", "a/../../b", "/abs", "", "a\\b"} {
+			err := store.Put(context.Background(), key, strings.NewReader("x"), 1, "")
+			assert.Error(t, err, key)
+		}
+	})
+
+	t.Run("Rejects truncated blobs", func(t *testing.T) {
+		err := store.Put(context.Background(), "short", strings.NewReader("abc"), 10, "")
+		assert.Error(t, err)
+		_, err = store.Get(context.Background(), "short")
+		assert.True(t, errors.Is(err, storage.ErrBlobNotFound))
+	})
+}
+
+func TestS3Store(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer server.Close()
+
+	store, err := storage.NewS3Store(storage.S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "attachments",
+		AccessKey: "minio",
+		SecretKey: "minio123",
+	})
+	require.NoError(t, err)
+	testStore(t, store)
+}
+
+// TestS3StoreMinIO runs against a real S3-compatible service when
+// S3_TEST_ENDPOINT is set, e.g. to http://localhost:9000 with the MinIO
+// service of docker-compose
+func TestS3StoreMinIO(t *testing.T) {
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("S3_TEST_ENDPOINT is not set")
+	}
+
+	store, err := storage.NewS3Store(storage.S3Config{
+		Endpoint:  endpoint,
+		Bucket:    "chat-api-test",
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.EnsureBucket(context.Background()))
+	testStore(t, store)
+}
@@ -0,0 +1,343 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Limits of the fields of a preview, in characters
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+	maxSiteNameLength    = 100
+	maxURLLength         = 2048
+)
+
+var (
+	// ErrBlocked is returned for URLs the fetcher may not visit: other
+	// schemes than http and https, hosts that are not allowed and private
+	// addresses
+	ErrBlocked = errors.New("URL is blocked")
+	// ErrNoPreview is returned for pages that cannot be previewed, such as
+	// failed requests, documents that are not HTML and pages with neither a
+	// title nor a description
+	ErrNoPreview = errors.New("page has no preview")
+)
+
+// blockedPrefixes are ranges of addresses that are not reachable on the
+// internet, on top of the loopback, private, link-local and multicast ones
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // Reserved
+	netip.MustParsePrefix("64:ff9b::/96"),   // NAT64, may map to private IPv4 addresses
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local-use NAT64
+	netip.MustParsePrefix("2002::/16"),      // 6to4, may embed private IPv4 addresses
+	netip.MustParsePrefix("2001:db8::/32"),  // Documentation
+}
+
+// Preview is the summary of a web page given by its OpenGraph tags, or its
+// title and description when it has none
+type Preview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Config limits what a Fetcher visits and how much it reads
+type Config struct {
+	Timeout      time.Duration // Limit of a whole fetch, redirects included
+	MaxBodySize  int64         // Bytes of a page read at most
+	MaxRedirects int
+	AllowedHosts []string // When set, only these hosts and their subdomains are visited
+	BlockedHosts []string // Hosts never visited, along with their subdomains
+	AllowPrivate bool     // Visit private and loopback addresses too, for tests
+	UserAgent    string
+}
+
+// Fetcher fetches previews of web pages on behalf of users. It only connects
+// to public addresses, checked when connecting so that DNS answers cannot
+// point it at internal services.
+type Fetcher struct {
+	cfg    Config
+	client *http.Client
+}
+
+func NewFetcher(cfg Config) *Fetcher {
+	f := &Fetcher{cfg: cfg}
+
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: f.checkAddress}
+	transport := &http.Transport{
+		Proxy:                  nil, // A proxy would connect on our behalf, past the address check
+		DialContext:            dialer.DialContext,
+		ForceAttemptHTTP2:      true,
+		TLSHandshakeTimeout:    cfg.Timeout,
+		ResponseHeaderTimeout:  cfg.Timeout,
+		MaxResponseHeaderBytes: 64 << 10,
+		MaxIdleConns:           10,
+		IdleConnTimeout:        90 * time.Second,
+	}
+	f.client = &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("more than %d redirects: %w", cfg.MaxRedirects, ErrNoPreview)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// checkAddress refuses connections to addresses that are not public
+func (f *Fetcher) checkAddress(network, address string, _ syscall.RawConn) error {
+	if f.cfg.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%s: %w", address, ErrBlocked)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !PublicAddr(addr) {
+		return fmt.Errorf("%s is not a public address: %w", host, ErrBlocked)
+	}
+	return nil
+}
+
+// PublicAddr reports whether an address is reachable on the internet
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHost reports whether host is one of the hosts listed or a subdomain
+// of one of them. IP addresses only match themselves.
+func matchHost(host string, hosts []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	_, err := netip.ParseAddr(host)
+	isIP := err == nil
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h != "" && (host == h || (!isIP && strings.HasSuffix(host, "."+h))) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHosts reports whether a list of hosts has any non-blank entry
+func hasHosts(hosts []string) bool {
+	for _, h := range hosts {
+		if strings.TrimSpace(h) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURL refuses URLs the fetcher may not visit
+func (f *Fetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme %q: %w", u.Scheme, ErrBlocked)
+	}
+	if u.User != nil {
+		return fmt.Errorf("URL with credentials: %w", ErrBlocked)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL without host: %w", ErrBlocked)
+	}
+	if matchHost(host, f.cfg.BlockedHosts) {
+		return fmt.Errorf("host %s is blocked: %w", host, ErrBlocked)
+	}
+	if hasHosts(f.cfg.AllowedHosts) && !matchHost(host, f.cfg.AllowedHosts) {
+		return fmt.Errorf("host %s is not allowed: %w", host, ErrBlocked)
+	}
+	return nil
+}
+
+// Fetch downloads the page at rawURL and returns its preview. At most
+// MaxBodySize bytes of the page are read, which is plenty to reach the tags
+// in its head.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", ErrBlocked)
+	}
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", ErrBlocked)
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", u.Redacted(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned %d: %w", u.Redacted(), resp.StatusCode, ErrNoPreview)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%s is %q: %w", u.Redacted(), mediaType, ErrNoPreview)
+	}
+
+	return parsePage(io.LimitReader(resp.Body, f.cfg.MaxBodySize), resp.Request.URL)
+}
+
+// parsePage reads the head of an HTML page and builds its preview. Links are
+// resolved against base, the URL the page was fetched from.
+func parsePage(r io.Reader, base *url.URL) (*Preview, error) {
+	meta := make(map[string]string)
+	var title strings.Builder
+	inTitle := false
+
+	z := html.NewTokenizer(r)
+parse:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			break parse // End of the page, or of what was read of it
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				break parse
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = tt == html.StartTagToken && title.Len() == 0
+			case atom.Body:
+				break parse
+			case atom.Meta:
+				if hasAttr {
+					readMeta(z, meta)
+				}
+			}
+		}
+	}
+
+	p := &Preview{
+		URL:         resolve(base, meta["og:url"]),
+		Title:       clean(first(meta["og:title"], meta["twitter:title"], title.String()), maxTitleLength),
+		Description: clean(first(meta["og:description"], meta["twitter:description"], meta["description"]), maxDescriptionLength),
+		Image:       resolve(base, first(meta["og:image"], meta["og:image:url"], meta["og:image:secure_url"], meta["twitter:image"])),
+		SiteName:    clean(meta["og:site_name"], maxSiteNameLength),
+	}
+	if p.URL == "" {
+		p.URL = base.String()
+	}
+	if p.Title == "" && p.Description == "" {
+		return nil, fmt.Errorf("%s: %w", base.Redacted(), ErrNoPreview)
+	}
+	return p, nil
+}
+
+// readMeta records the content of a meta tag under its property or name.
+// The first tag with a given key wins.
+func readMeta(z *html.Tokenizer, meta map[string]string) {
+	var key, content string
+	for {
+		name, value, more := z.TagAttr()
+		switch string(name) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(string(value)))
+			}
+		case "content":
+			content = string(value)
+		}
+		if !more {
+			break
+		}
+	}
+	if _, seen := meta[key]; key != "" && !seen {
+		meta[key] = content
+	}
+}
+
+// first returns the first non-blank value
+func first(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean collapses the whitespace of a text and shortens it to max characters
+func clean(s string, max int) string {
+	s = strings.Join(strings.Fields(strings.ToValidUTF8(s, "")), " ")
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+// resolve returns a link of the page as an absolute http or https URL, or
+// an empty string when it is not one
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	s := u.String()
+	if len(s) > maxURLLength {
+		return ""
+	}
+	return s
+}